@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr/nip19"
+	"fiatjaf.com/nostr/sdk"
+)
+
+// these endpoints expose nostr profiles as read-only ActivityPub actors so people on the fediverse
+// can look them up and read their notes. we don't accept follows or any other activity.
+
+const activityStreamsPublic = "https://www.w3.org/ns/activitystreams#Public"
+
+type WebFingerResponse struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []WebFingerLink `json:"links"`
+}
+
+type WebFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
+
+type ActivityPubImage struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type ActivityPubActor struct {
+	Context                   []string          `json:"@context"`
+	ID                        string            `json:"id"`
+	Type                      string            `json:"type"`
+	PreferredUsername         string            `json:"preferredUsername"`
+	Name                      string            `json:"name,omitempty"`
+	Summary                   string            `json:"summary,omitempty"`
+	URL                       string            `json:"url"`
+	Icon                      *ActivityPubImage `json:"icon,omitempty"`
+	Image                     *ActivityPubImage `json:"image,omitempty"`
+	Inbox                     string            `json:"inbox"`
+	Outbox                    string            `json:"outbox"`
+	ManuallyApprovesFollowers bool              `json:"manuallyApprovesFollowers"`
+	Discoverable              bool              `json:"discoverable"`
+	Published                 string            `json:"published,omitempty"`
+}
+
+type ActivityPubNote struct {
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	AttributedTo string   `json:"attributedTo"`
+	Content      string   `json:"content"`
+	URL          string   `json:"url"`
+	Published    string   `json:"published"`
+	To           []string `json:"to"`
+}
+
+type ActivityPubCreate struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Actor     string          `json:"actor"`
+	Published string          `json:"published"`
+	To        []string        `json:"to"`
+	Object    ActivityPubNote `json:"object"`
+}
+
+type ActivityPubOutbox struct {
+	Context      []string            `json:"@context"`
+	ID           string              `json:"id"`
+	Type         string              `json:"type"`
+	TotalItems   int                 `json:"totalItems"`
+	OrderedItems []ActivityPubCreate `json:"orderedItems"`
+}
+
+func activityPubActorID(npub string) string {
+	return "https://" + s.Domain + "/ap/" + npub
+}
+
+func renderWebFinger(w http.ResponseWriter, r *http.Request) {
+	// resource can be acct:npub1...@domain or https://domain/npub1...
+	resource := r.URL.Query().Get("resource")
+	var npub string
+	if acct, ok := strings.CutPrefix(resource, "acct:"); ok {
+		user, host, ok := strings.Cut(acct, "@")
+		if !ok || host != s.Domain {
+			http.Error(w, "unknown resource", http.StatusNotFound)
+			return
+		}
+		npub = user
+	} else if path, ok := strings.CutPrefix(resource, "https://"+s.Domain+"/"); ok {
+		npub = path
+	}
+
+	if prefix, _, err := nip19.Decode(npub); err != nil || prefix != "npub" {
+		http.Error(w, "unknown resource", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=86400, s-maxage=86400")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Add("Content-Type", "application/jrd+json")
+	json.NewEncoder(w).Encode(WebFingerResponse{
+		Subject: "acct:" + npub + "@" + s.Domain,
+		Aliases: []string{"https://" + s.Domain + "/" + npub, activityPubActorID(npub)},
+		Links: []WebFingerLink{
+			{
+				Rel:  "self",
+				Type: "application/activity+json",
+				Href: activityPubActorID(npub),
+			},
+			{
+				Rel:  "http://webfinger.net/rel/profile-page",
+				Type: "text/html",
+				Href: "https://" + s.Domain + "/" + npub,
+			},
+		},
+	})
+}
+
+// activityPubProfile loads the profile for the npub in the path, writing an error and returning
+// false if it doesn't exist or isn't allowed
+func activityPubProfile(w http.ResponseWriter, r *http.Request) (sdk.ProfileMetadata, bool) {
+	ctx := r.Context()
+
+	code := r.PathValue("npub")
+	if !strings.HasPrefix(code, "npub1") {
+		http.Error(w, "not found", http.StatusNotFound)
+		return sdk.ProfileMetadata{}, false
+	}
+	pp := sdk.InputToProfile(ctx, code)
+	if pp == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return sdk.ProfileMetadata{}, false
+	}
+	pubkey := pp.PublicKey
+
+	if banned, _ := isPubkeyBanned(pubkey); banned {
+		w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
+		http.Error(w, "pubkey banned", http.StatusNotFound)
+		return sdk.ProfileMetadata{}, false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	profile := sys.FetchProfileMetadata(ctx, pubkey)
+	if reason := profileBlockedReason(ctx, profile); reason != "" {
+		w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
+		http.Error(w, reason, http.StatusNotFound)
+		return sdk.ProfileMetadata{}, false
+	}
+
+	return profile, true
+}
+
+func renderActivityPubActor(w http.ResponseWriter, r *http.Request) {
+	profile, ok := activityPubProfile(w, r)
+	if !ok {
+		return
+	}
+
+	npub := profile.Npub()
+	actor := ActivityPubActor{
+		Context:           []string{"https://www.w3.org/ns/activitystreams"},
+		ID:                activityPubActorID(npub),
+		Type:              "Person",
+		PreferredUsername: npub,
+		Name:              profile.ShortName(),
+		Summary:           basicFormatting(html.EscapeString(profile.About), false, false, false),
+		URL:               "https://" + s.Domain + "/" + npub,
+		Inbox:             activityPubActorID(npub) + "/inbox",
+		Outbox:            activityPubActorID(npub) + "/outbox",
+		// we can't accept follows, so don't let servers think they succeeded
+		ManuallyApprovesFollowers: true,
+		Discoverable:              true,
+	}
+	if profile.Picture != "" {
+		actor.Icon = &ActivityPubImage{Type: "Image", URL: profile.Picture}
+	}
+	if profile.Banner != "" {
+		actor.Image = &ActivityPubImage{Type: "Image", URL: profile.Banner}
+	}
+	if profile.Event != nil {
+		actor.Published = profile.Event.CreatedAt.Time().UTC().Format(time.RFC3339)
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=3600, s-maxage=3600")
+	w.Header().Add("Content-Type", "application/activity+json")
+	json.NewEncoder(w).Encode(actor)
+}
+
+func renderActivityPubOutbox(w http.ResponseWriter, r *http.Request) {
+	profile, ok := activityPubProfile(w, r)
+	if !ok {
+		return
+	}
+
+	npub := profile.Npub()
+	actorID := activityPubActorID(npub)
+
+	tab, _ := getProfileTab("notes")
+	lastNotes, justFetched := authorLastEvents(r.Context(), profile.PubKey, tab, 0)
+
+	items := make([]ActivityPubCreate, 0, len(lastNotes))
+	for _, ee := range lastNotes {
+		link := "https://" + s.Domain + "/" + ee.Nevent()
+		published := ee.CreatedAt.Time().UTC().Format(time.RFC3339)
+		items = append(items, ActivityPubCreate{
+			ID:        link + "#create",
+			Type:      "Create",
+			Actor:     actorID,
+			Published: published,
+			To:        []string{activityStreamsPublic},
+			Object: ActivityPubNote{
+				ID:           link,
+				Type:         "Note",
+				AttributedTo: actorID,
+				Content:      absoluteLinks(ee.RssContent()),
+				URL:          link,
+				Published:    published,
+				To:           []string{activityStreamsPublic},
+			},
+		})
+	}
+
+	if justFetched {
+		w.Header().Set("Cache-Control", "public, s-maxage=5, max-age=5")
+	} else {
+		w.Header().Set("Cache-Control", "public, s-maxage=1800, max-age=1800")
+	}
+	w.Header().Add("Content-Type", "application/activity+json")
+	json.NewEncoder(w).Encode(ActivityPubOutbox{
+		Context:      []string{"https://www.w3.org/ns/activitystreams"},
+		ID:           actorID + "/outbox",
+		Type:         "OrderedCollection",
+		TotalItems:   len(items),
+		OrderedItems: items,
+	})
+}
+
+func renderActivityPubInbox(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "this is a read-only bridge, nostr profiles can't be followed from here", http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testNpub = "npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6"
+
+func TestRenderWebFinger(t *testing.T) {
+	domain := s.Domain
+	s.Domain = "njump.test"
+	defer func() { s.Domain = domain }()
+
+	for _, tc := range []struct {
+		resource string
+		status   int
+	}{
+		{"acct:" + testNpub + "@njump.test", http.StatusOK},
+		{"https://njump.test/" + testNpub, http.StatusOK},
+		{"acct:" + testNpub + "@elsewhere.com", http.StatusNotFound},
+		{"acct:" + testNpub, http.StatusNotFound},
+		{"https://elsewhere.com/" + testNpub, http.StatusNotFound},
+		{"acct:fiatjaf@njump.test", http.StatusNotFound},
+		{"acct:note1fnxktkcy9pjwucqwa9mddn7v03wwwsu9j33309ydraxewrvg5hesqck5yj@njump.test", http.StatusNotFound},
+		{"", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		renderWebFinger(w, httptest.NewRequest("GET", "/.well-known/webfinger?resource="+url.QueryEscape(tc.resource), nil))
+		assert.Equal(t, tc.status, w.Code, tc.resource)
+
+		if tc.status == http.StatusOK {
+			var res WebFingerResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			assert.Equal(t, "acct:"+testNpub+"@njump.test", res.Subject)
+			assert.Contains(t, res.Aliases, "https://njump.test/ap/"+testNpub)
+		}
+	}
+}
+
+func TestAbsoluteLinks(t *testing.T) {
+	domain := s.Domain
+	s.Domain = "njump.test"
+	defer func() { s.Domain = domain }()
+
+	assert.Equal(t,
+		`In reply to <a href='https://njump.test/nevent1abc'>nevent1a…</a> and <a href="https://njump.test/npub1xyz">someone</a>`,
+		absoluteLinks(`In reply to <a href='/nevent1abc'>nevent1a…</a> and <a href="/npub1xyz">someone</a>`))
+
+	// links elsewhere stay the same
+	for _, html := range []string{
+		`<a href="https://example.com/">x</a>`,
+		`<img src="//cdn.example.com/a.png">`,
+		`<a href="#top">top</a>`,
+	} {
+		assert.Equal(t, html, absoluteLinks(html))
+	}
+}
@@ -0,0 +1,619 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/sdk"
+)
+
+// the /api/v1/ endpoints return the same things we use to render the pages, as JSON
+
+type APIProfile struct {
+	PubKey      nostr.PubKey `json:"pubkey"`
+	Npub        string       `json:"npub"`
+	Name        string       `json:"name,omitempty"`
+	DisplayName string       `json:"display_name,omitempty"`
+	About       string       `json:"about,omitempty"`
+	Picture     string       `json:"picture,omitempty"`
+	Banner      string       `json:"banner,omitempty"`
+	Website     string       `json:"website,omitempty"`
+	NIP05       string       `json:"nip05,omitempty"`
+	Event       *nostr.Event `json:"event,omitempty"`
+}
+
+func newAPIProfile(profile sdk.ProfileMetadata) APIProfile {
+	return APIProfile{
+		PubKey:      profile.PubKey,
+		Npub:        profile.Npub(),
+		Name:        profile.Name,
+		DisplayName: profile.DisplayName,
+		About:       profile.About,
+		Picture:     profile.Picture,
+		Banner:      profile.Banner,
+		Website:     profile.Website,
+		NIP05:       profile.NIP05,
+		Event:       profile.Event,
+	}
+}
+
+type APIEventResponse struct {
+	Event           *nostr.Event `json:"event"`
+	Author          APIProfile   `json:"author"`
+	Nevent          string       `json:"nevent"`
+	Naddr           string       `json:"naddr,omitempty"`
+	KindDescription string       `json:"kind_description,omitempty"`
+	KindNIP         string       `json:"kind_nip,omitempty"`
+	Relays          []string     `json:"relays"`
+	Subject         string       `json:"subject,omitempty"`
+	Summary         string       `json:"summary,omitempty"`
+	ContentHTML     string       `json:"content_html"`
+	Image           string       `json:"image,omitempty"`
+	Cover           string       `json:"cover,omitempty"`
+	Video           string       `json:"video,omitempty"`
+	VideoType       string       `json:"video_type,omitempty"`
+	Metadata        any          `json:"metadata,omitempty"`
+}
+
+type APIGroupMetadata struct {
+	ID      string `json:"id"`
+	Relay   string `json:"relay,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Picture string `json:"picture,omitempty"`
+	About   string `json:"about,omitempty"`
+}
+
+type APIProfileResponse struct {
+	APIProfile
+	Nprofile string   `json:"nprofile"`
+	Relays   []string `json:"relays"`
+}
+
+type APIError struct {
+	Error string `json:"error"`
+}
+
+func writeAPIResponse(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+type APIContact struct {
+	PubKey  nostr.PubKey `json:"pubkey"`
+	Npub    string       `json:"npub"`
+	Name    string       `json:"name,omitempty"`
+	Picture string       `json:"picture,omitempty"`
+}
+
+func newAPIContacts(contacts []ContactInfo) []APIContact {
+	list := make([]APIContact, len(contacts))
+	for i, contact := range contacts {
+		list[i] = APIContact{
+			PubKey:  contact.PubKey,
+			Npub:    contact.Npub,
+			Name:    contact.Name,
+			Picture: contact.Picture,
+		}
+	}
+	return list
+}
+
+// newAPIProfilePointer is for the profiles that may not be there at all
+func newAPIProfilePointer(profile *sdk.ProfileMetadata) *APIProfile {
+	if profile == nil || profile.PubKey == nostr.ZeroPK {
+		return nil
+	}
+	p := newAPIProfile(*profile)
+	return &p
+}
+
+type APIFileMetadata struct {
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type,omitempty"`
+	Image    string `json:"image,omitempty"`
+	Summary  string `json:"summary,omitempty"`
+	Size     string `json:"size,omitempty"`
+	Dim      string `json:"dim,omitempty"`
+	Magnet   string `json:"magnet,omitempty"`
+}
+
+type APILiveParticipant struct {
+	APIProfile
+	Role string `json:"role,omitempty"`
+}
+
+type APILiveEvent struct {
+	Title        string               `json:"title,omitempty"`
+	Summary      string               `json:"summary,omitempty"`
+	Image        string               `json:"image,omitempty"`
+	Status       string               `json:"status,omitempty"`
+	Hashtags     []string             `json:"hashtags,omitempty"`
+	Host         *APIProfile          `json:"host,omitempty"`
+	StreamURL    string               `json:"stream_url,omitempty"`
+	RecordingURL string               `json:"recording_url,omitempty"`
+	StartsAt     string               `json:"starts_at,omitempty"`
+	Participants []APILiveParticipant `json:"participants"`
+}
+
+type APICalendarParticipant struct {
+	PubKey nostr.PubKey `json:"pubkey"`
+	Role   string       `json:"role,omitempty"`
+}
+
+type APICalendarEvent struct {
+	Naddr        string                   `json:"naddr,omitempty"`
+	Title        string                   `json:"title,omitempty"`
+	Image        string                   `json:"image,omitempty"`
+	Start        int64                    `json:"start"`
+	End          int64                    `json:"end,omitempty"`
+	StartTzid    string                   `json:"start_tzid,omitempty"`
+	EndTzid      string                   `json:"end_tzid,omitempty"`
+	Locations    []string                 `json:"locations,omitempty"`
+	Hashtags     []string                 `json:"hashtags,omitempty"`
+	Participants []APICalendarParticipant `json:"participants,omitempty"`
+}
+
+func newAPICalendarEvent(naddr string, ce Kind31922Or31923Metadata) APICalendarEvent {
+	event := APICalendarEvent{
+		Naddr:     naddr,
+		Title:     ce.Title,
+		Image:     ce.Image,
+		Start:     ce.Start.Unix(),
+		StartTzid: ce.StartTzid,
+		EndTzid:   ce.EndTzid,
+		Locations: ce.Locations,
+		Hashtags:  ce.Hashtags,
+	}
+	if !ce.End.IsZero() {
+		event.End = ce.End.Unix()
+	}
+	for _, participant := range ce.Participants {
+		event.Participants = append(event.Participants, APICalendarParticipant{participant.PubKey, participant.Role})
+	}
+	return event
+}
+
+type APICalendar struct {
+	Title    string             `json:"title,omitempty"`
+	Upcoming []APICalendarEvent `json:"upcoming"`
+	Past     []APICalendarEvent `json:"past"`
+}
+
+func newAPICalendarEvents(entries []CalendarEntry) []APICalendarEvent {
+	events := make([]APICalendarEvent, len(entries))
+	for i, entry := range entries {
+		events[i] = newAPICalendarEvent(entry.Naddr, entry.Kind31922Or31923Metadata)
+	}
+	return events
+}
+
+type APIWiki struct {
+	Handle      string `json:"handle"`
+	Title       string `json:"title,omitempty"`
+	Summary     string `json:"summary,omitempty"`
+	PublishedAt int64  `json:"published_at,omitempty"`
+}
+
+type APISet struct {
+	Title       string       `json:"title,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Contacts    []APIContact `json:"contacts"`
+}
+
+type APIHighlight struct {
+	Author      *APIProfile `json:"author,omitempty"`
+	SourceEvent string      `json:"source_event,omitempty"`
+	SourceURL   string      `json:"source_url,omitempty"`
+	SourceName  string      `json:"source_name,omitempty"`
+	Context     string      `json:"context,omitempty"`
+	Comment     string      `json:"comment,omitempty"`
+}
+
+type APIZap struct {
+	Amount      int64       `json:"amount"`
+	Sender      *APIProfile `json:"sender,omitempty"`
+	Recipient   *APIProfile `json:"recipient,omitempty"`
+	ZappedEvent string      `json:"zapped_event,omitempty"`
+	Comment     string      `json:"comment,omitempty"`
+}
+
+type APIBadgeDefinition struct {
+	Naddr       string `json:"naddr"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	Thumb       string `json:"thumb,omitempty"`
+}
+
+type APIBadge struct {
+	Badge    *APIBadgeDefinition  `json:"badge,omitempty"`
+	Awardees []APIContact         `json:"awardees,omitempty"`
+	Badges   []APIBadgeDefinition `json:"badges,omitempty"`
+}
+
+func newAPIBadgeDefinition(badge BadgeDefinition) APIBadgeDefinition {
+	return APIBadgeDefinition{
+		Naddr:       badge.Naddr,
+		Name:        badge.Name,
+		Description: badge.Description,
+		Image:       badge.Image,
+		Thumb:       badge.Thumb,
+	}
+}
+
+type APIShippingZone struct {
+	ID      string   `json:"id,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	Cost    string   `json:"cost,omitempty"`
+	Regions []string `json:"regions,omitempty"`
+}
+
+type APIListing struct {
+	Naddr     string            `json:"naddr,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Summary   string            `json:"summary,omitempty"`
+	Price     string            `json:"price,omitempty"`
+	Currency  string            `json:"currency,omitempty"`
+	Frequency string            `json:"frequency,omitempty"`
+	Images    []string          `json:"images,omitempty"`
+	Location  string            `json:"location,omitempty"`
+	Status    string            `json:"status,omitempty"`
+	Quantity  *int              `json:"quantity,omitempty"`
+	Specs     [][]string        `json:"specs,omitempty"`
+	Shipping  []APIShippingZone `json:"shipping,omitempty"`
+	Hashtags  []string          `json:"hashtags,omitempty"`
+	Stall     string            `json:"stall,omitempty"`
+	StallName string            `json:"stall_name,omitempty"`
+	Products  []APIListing      `json:"products,omitempty"`
+}
+
+func newAPIListing(listing ListingMetadata) APIListing {
+	api := APIListing{
+		Naddr:     listing.Naddr,
+		Title:     listing.Title,
+		Summary:   listing.Summary,
+		Price:     listing.Price,
+		Currency:  listing.Currency,
+		Frequency: listing.Frequency,
+		Images:    listing.Images,
+		Location:  listing.Location,
+		Status:    listing.Status,
+		Quantity:  listing.Quantity,
+		Specs:     listing.Specs,
+		Hashtags:  listing.Hashtags,
+		Stall:     listing.Stall,
+		StallName: listing.StallName,
+	}
+	for _, zone := range listing.Shipping {
+		api.Shipping = append(api.Shipping, APIShippingZone(zone))
+	}
+	for _, product := range listing.Products {
+		api.Products = append(api.Products, newAPIListing(product))
+	}
+	return api
+}
+
+type APIPollOption struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Votes int    `json:"votes"`
+}
+
+type APIPoll struct {
+	Options     []APIPollOption `json:"options"`
+	Relays      []string        `json:"relays,omitempty"`
+	PollType    string          `json:"poll_type,omitempty"`
+	EndsAt      nostr.Timestamp `json:"ends_at,omitempty"`
+	TotalVoters int             `json:"total_voters"`
+}
+
+type APIGitRepository struct {
+	Naddr       string       `json:"naddr,omitempty"`
+	ID          string       `json:"id,omitempty"`
+	Name        string       `json:"name,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Web         []string     `json:"web,omitempty"`
+	Clone       []string     `json:"clone,omitempty"`
+	Relays      []string     `json:"relays,omitempty"`
+	Maintainers []APIContact `json:"maintainers,omitempty"`
+	Hashtags    []string     `json:"hashtags,omitempty"`
+}
+
+type APIGitItem struct {
+	Nevent    string `json:"nevent"`
+	Subject   string `json:"subject,omitempty"`
+	Status    string `json:"status,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+type APIGitComment struct {
+	Nevent      string     `json:"nevent"`
+	Author      APIProfile `json:"author"`
+	CreatedAt   string     `json:"created_at,omitempty"`
+	ContentHTML string     `json:"content_html"`
+}
+
+type APIGit struct {
+	Repository APIGitRepository `json:"repository"`
+	Issues     []APIGitItem     `json:"issues,omitempty"`
+	Patches    []APIGitItem     `json:"patches,omitempty"`
+	Subject    string           `json:"subject,omitempty"`
+	Status     string           `json:"status,omitempty"`
+	Labels     []string         `json:"labels,omitempty"`
+	Comments   []APIGitComment  `json:"comments,omitempty"`
+}
+
+func newAPIGitItems(items []GitItem) []APIGitItem {
+	list := make([]APIGitItem, len(items))
+	for i, item := range items {
+		list[i] = APIGitItem(item)
+	}
+	return list
+}
+
+func newAPIGit(git GitMetadata) APIGit {
+	api := APIGit{
+		Repository: APIGitRepository{
+			Naddr:       git.Repository.Naddr,
+			ID:          git.Repository.ID,
+			Name:        git.Repository.Name,
+			Description: git.Repository.Description,
+			Web:         git.Repository.Web,
+			Clone:       git.Repository.Clone,
+			Relays:      git.Repository.Relays,
+			Maintainers: newAPIContacts(git.Repository.Maintainers),
+			Hashtags:    git.Repository.Hashtags,
+		},
+		Issues:  newAPIGitItems(git.Issues),
+		Patches: newAPIGitItems(git.Patches),
+		Subject: git.Subject,
+		Status:  git.Status,
+		Labels:  git.Labels,
+	}
+	// the diff is left out, it is right there in the event content
+	for _, comment := range git.Comments {
+		api.Comments = append(api.Comments, APIGitComment{
+			Nevent:      comment.Nevent,
+			Author:      newAPIProfile(comment.Author),
+			CreatedAt:   comment.CreatedAt,
+			ContentHTML: string(comment.Content),
+		})
+	}
+	return api
+}
+
+// apiEventMetadata turns the parsed kind-specific metadata for the kind we got into what the API returns,
+// it must return a plain nil when there is nothing so the field gets omitted
+func apiEventMetadata(data Data) any {
+	switch data.templateId {
+	case FileMetadata:
+		if data.kind1063Metadata == nil {
+			return nil
+		}
+		return APIFileMetadata{
+			URL:      data.kind1063Metadata.URL,
+			MimeType: data.kind1063Metadata.M,
+			Image:    data.kind1063Metadata.Image,
+			Summary:  data.kind1063Metadata.Summary,
+			Size:     data.kind1063Metadata.Size,
+			Dim:      data.kind1063Metadata.Dim,
+			Magnet:   data.kind1063Metadata.Magnet,
+		}
+	case LiveEvent:
+		if data.kind30311Metadata == nil {
+			return nil
+		}
+		// the chat is left out, it moves too fast for something we cache
+		le := data.kind30311Metadata
+		participants := make([]APILiveParticipant, len(le.People))
+		for i, person := range le.People {
+			participants[i] = APILiveParticipant{newAPIProfile(person.Profile), person.Role}
+		}
+		return APILiveEvent{
+			Title:        le.Title,
+			Summary:      le.Summary,
+			Image:        le.Image,
+			Status:       le.Status,
+			Hashtags:     le.Hashtags,
+			Host:         newAPIProfilePointer(le.Host),
+			StreamURL:    le.StreamURL,
+			RecordingURL: le.RecordingURL,
+			StartsAt:     le.StartsAt,
+			Participants: participants,
+		}
+	case CalendarEvent:
+		if data.kind31922Or31923Metadata == nil {
+			return nil
+		}
+		return newAPICalendarEvent(data.naddr, *data.kind31922Or31923Metadata)
+	case CalendarCollection:
+		return APICalendar{
+			Title:    data.CalendarMetadata.Title,
+			Upcoming: newAPICalendarEvents(data.CalendarMetadata.Upcoming),
+			Past:     newAPICalendarEvents(data.CalendarMetadata.Past),
+		}
+	case WikiEvent:
+		wiki := APIWiki{
+			Handle:  data.Kind30818Metadata.Handle,
+			Title:   data.Kind30818Metadata.Title,
+			Summary: data.Kind30818Metadata.Summary,
+		}
+		if !data.Kind30818Metadata.PublishedAt.IsZero() {
+			wiki.PublishedAt = data.Kind30818Metadata.PublishedAt.Unix()
+		}
+		return wiki
+	case FollowSet, StarterPack:
+		return APISet{
+			Title:       data.Nip51SetMetadata.Title,
+			Description: data.Nip51SetMetadata.Description,
+			Contacts:    newAPIContacts(data.Nip51SetMetadata.Contacts),
+		}
+	case Highlight:
+		return APIHighlight{
+			Author:      newAPIProfilePointer(&data.Kind9802Metadata.Author),
+			SourceEvent: data.Kind9802Metadata.SourceEvent,
+			SourceURL:   data.Kind9802Metadata.SourceURL,
+			SourceName:  data.Kind9802Metadata.SourceName,
+			Context:     data.Kind9802Metadata.Context,
+			Comment:     data.Kind9802Metadata.Comment,
+		}
+	case Zap:
+		return APIZap{
+			Amount:      data.Kind9735Metadata.Amount,
+			Sender:      newAPIProfilePointer(data.Kind9735Metadata.Sender),
+			Recipient:   newAPIProfilePointer(data.Kind9735Metadata.Recipient),
+			ZappedEvent: data.Kind9735Metadata.ZappedEvent,
+			Comment:     data.Kind9735Metadata.Comment,
+		}
+	case Badge:
+		badge := APIBadge{}
+		if data.BadgeMetadata.Badge.Naddr != "" {
+			definition := newAPIBadgeDefinition(data.BadgeMetadata.Badge)
+			badge.Badge = &definition
+		}
+		if len(data.BadgeMetadata.Awardees) > 0 {
+			badge.Awardees = newAPIContacts(data.BadgeMetadata.Awardees)
+		}
+		for _, definition := range data.BadgeMetadata.Badges {
+			badge.Badges = append(badge.Badges, newAPIBadgeDefinition(definition))
+		}
+		return badge
+	case Listing:
+		return newAPIListing(data.ListingMetadata)
+	case Poll:
+		options := make([]APIPollOption, len(data.PollMetadata.Options))
+		for i, option := range data.PollMetadata.Options {
+			options[i] = APIPollOption(option)
+		}
+		return APIPoll{
+			Options:     options,
+			Relays:      data.PollMetadata.Relays,
+			PollType:    data.PollMetadata.PollType,
+			EndsAt:      data.PollMetadata.EndsAt,
+			TotalVoters: data.PollMetadata.TotalVoters,
+		}
+	case Git:
+		return newAPIGit(data.GitMetadata)
+	case GroupMetadata:
+		return APIGroupMetadata{
+			ID:      data.Kind39000Metadata.Address.ID,
+			Relay:   data.Kind39000Metadata.Address.Relay,
+			Name:    data.Kind39000Metadata.Name,
+			Picture: data.Kind39000Metadata.Picture,
+			About:   data.Kind39000Metadata.About,
+		}
+	}
+
+	if data.event.Kind == 9 && data.Kind39000Metadata.Address.ID != "" {
+		// group messages carry the group they belong to
+		return APIGroupMetadata{
+			ID:    data.Kind39000Metadata.Address.ID,
+			Relay: data.Kind39000Metadata.Address.Relay,
+			Name:  data.Kind39000Metadata.Name,
+		}
+	}
+	return nil
+}
+
+func renderAPIEvent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	code := strings.TrimPrefix(r.PathValue("code"), "nostr:")
+
+	if strings.HasPrefix(code, "npub1") || strings.HasPrefix(code, "nprofile1") {
+		writeAPIResponse(w, http.StatusBadRequest, APIError{"use /api/v1/profile/ for profiles"})
+		return
+	}
+
+	data, err := grabData(ctx, code)
+	if err != nil {
+		w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
+		log.Warn().Err(err).Str("code", code).Msg("event error on api")
+		writeAPIResponse(w, http.StatusNotFound, APIError{"error fetching event: " + err.Error()})
+		return
+	} else if data.event.Event == nil {
+		w.Header().Set("Cache-Control", "public, s-maxage=1200, max-age=1200")
+		writeAPIResponse(w, http.StatusNotFound, APIError{"no event found"})
+		return
+	}
+
+	sys.TrackEventAccessTime(data.event.ID)
+
+	// same as the pages, some kinds keep changing because of their replies, votes and so on
+	setEventCacheHeaders(w, data)
+
+	relays := data.event.relays
+	if relays == nil {
+		relays = []string{}
+	}
+
+	writeAPIResponse(w, http.StatusOK, APIEventResponse{
+		Event:           data.event.Event,
+		Author:          newAPIProfile(data.event.author),
+		Nevent:          data.nevent,
+		Naddr:           data.naddr,
+		KindDescription: data.kindDescription,
+		KindNIP:         data.kindNIP,
+		Relays:          relays,
+		Subject:         data.event.subject,
+		Summary:         data.event.summary,
+		ContentHTML:     renderEventContent(ctx, data),
+		Image:           data.image,
+		Cover:           data.cover,
+		Video:           data.video,
+		VideoType:       data.videoType,
+		Metadata:        apiEventMetadata(data),
+	})
+}
+
+func renderAPIProfile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	code := strings.TrimPrefix(r.PathValue("code"), "nostr:")
+
+	pp := sdk.InputToProfile(ctx, code)
+	if pp == nil {
+		w.Header().Set("Cache-Control", "public, immutable, s-maxage=86400, max-age=86400")
+		writeAPIResponse(w, http.StatusNotFound, APIError{"invalid profile code"})
+		return
+	}
+
+	if banned, reason := isPubkeyBanned(pp.PublicKey); banned {
+		deleteAllEventsFromPubKey(pp.PublicKey)
+		w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
+		log.Warn().Str("pubkey", pp.PublicKey.Hex()).Str("reason", reason).Msg("pubkey banned")
+		writeAPIResponse(w, http.StatusNotFound, APIError{"pubkey banned"})
+		return
+	}
+
+	profileCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	profile := sys.FetchProfileMetadata(profileCtx, pp.PublicKey)
+
+	if reason := profileBlockedReason(ctx, profile); reason != "" {
+		w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
+		writeAPIResponse(w, http.StatusNotFound, APIError{reason})
+		return
+	}
+
+	if profile.Event == nil {
+		w.Header().Set("Cache-Control", "public, s-maxage=5, max-age=5")
+	} else {
+		w.Header().Set("Cache-Control", "public, s-maxage=1800, max-age=1800, stale-while-revalidate=31536000")
+	}
+
+	relays := sys.FetchOutboxRelays(profileCtx, pp.PublicKey, 3)
+	if relays == nil {
+		relays = []string{}
+	}
+
+	writeAPIResponse(w, http.StatusOK, APIProfileResponse{
+		APIProfile: newAPIProfile(profile),
+		Nprofile:   profile.Nprofile(ctx, sys, 2),
+		Relays:     relays,
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip52"
+	"fiatjaf.com/nostr/nip53"
+	"fiatjaf.com/nostr/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderEventContent(t *testing.T) {
+	note := Data{
+		templateId: Note,
+		event:      NewEnhancedEventWithoutMetadata(nostr.Event{Kind: 1}),
+		content:    "<b>hi</b> & bye",
+	}
+	rendered := renderEventContent(context.Background(), note)
+	assert.Contains(t, rendered, "&lt;b&gt;hi&lt;/b&gt;")
+	assert.NotContains(t, rendered, "<b>")
+
+	article := Data{
+		templateId: LongForm,
+		event:      NewEnhancedEventWithoutMetadata(nostr.Event{Kind: 30023}),
+		content:    "hello **world**",
+	}
+	assert.Contains(t, renderEventContent(context.Background(), article), "<strong>world</strong>")
+}
+
+func TestAPIEventMetadataOmitsMissing(t *testing.T) {
+	for _, templateId := range []TemplateID{FileMetadata, LiveEvent, CalendarEvent} {
+		metadata := apiEventMetadata(Data{templateId: templateId})
+		assert.True(t, metadata == nil, "template %d", templateId)
+
+		j, err := json.Marshal(APIEventResponse{Metadata: metadata})
+		assert.NoError(t, err)
+		assert.NotContains(t, string(j), `"metadata"`)
+	}
+}
+
+func TestAPIEventMetadataPoll(t *testing.T) {
+	metadata := apiEventMetadata(Data{
+		templateId: Poll,
+		PollMetadata: PollMetadata{
+			Options:     []PollOption{{ID: "a", Label: "yes", Votes: 3}, {ID: "b", Label: "no", Votes: 1}},
+			PollType:    "singlechoice",
+			EndsAt:      1700000000,
+			TotalVoters: 4,
+		},
+	})
+
+	j, err := json.Marshal(metadata)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"options": [{"id": "a", "label": "yes", "votes": 3}, {"id": "b", "label": "no", "votes": 1}],
+		"poll_type": "singlechoice",
+		"ends_at": 1700000000,
+		"total_voters": 4
+	}`, string(j))
+}
+
+func TestAPIEventMetadataGit(t *testing.T) {
+	metadata := apiEventMetadata(Data{
+		templateId: Git,
+		GitMetadata: GitMetadata{
+			Repository: GitRepository{ID: "njump", Name: "njump"},
+			Subject:    "fix things",
+			Status:     "open",
+			Diff:       template.HTML("<pre>--- a/main.go</pre>"),
+		},
+	})
+
+	j, err := json.Marshal(metadata)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(j), "diff")
+	assert.NotContains(t, string(j), "main.go")
+	assert.Contains(t, string(j), `"subject":"fix things"`)
+	assert.Contains(t, string(j), `"repository":{"id":"njump","name":"njump"}`)
+}
+
+func TestAPIEventMetadataLiveEvent(t *testing.T) {
+	pk, err := nostr.PubKeyFromHex("7bdef7be22dd8e59f4600e044aa53a1cf975a9dc7d27df5833bc77db784a5805")
+	assert.NoError(t, err)
+
+	metadata := apiEventMetadata(Data{
+		templateId: LiveEvent,
+		kind30311Metadata: &Kind30311Metadata{
+			LiveEvent: nip53.LiveEvent{Title: "stream"},
+			StreamURL: "https://example.com/live.m3u8",
+			People:    []LiveParticipant{{Profile: sdk.ProfileMetadata{PubKey: pk, Name: "host"}, Role: "Host"}},
+			Chat:      []LiveChatMessage{{Content: "gm"}},
+		},
+	})
+
+	live, ok := metadata.(APILiveEvent)
+	assert.True(t, ok)
+	assert.Nil(t, live.Host)
+	assert.Equal(t, []APILiveParticipant{{APIProfile: newAPIProfile(sdk.ProfileMetadata{PubKey: pk, Name: "host"}), Role: "Host"}}, live.Participants)
+
+	j, err := json.Marshal(metadata)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(j), "chat")
+	assert.NotContains(t, string(j), "gm")
+	assert.Contains(t, string(j), `"stream_url":"https://example.com/live.m3u8"`)
+}
+
+func TestNewAPICalendarEvent(t *testing.T) {
+	start := time.Date(2024, 7, 1, 18, 30, 0, 0, time.UTC)
+	event := newAPICalendarEvent("naddr1xyz", Kind31922Or31923Metadata{nip52.CalendarEvent{Title: "meetup", Start: start}})
+	assert.Equal(t, start.Unix(), event.Start)
+	assert.Zero(t, event.End)
+
+	j, err := json.Marshal(event)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"naddr": "naddr1xyz", "title": "meetup", "start": 1719858600}`, string(j))
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+	"github.com/dgraph-io/ristretto"
+)
+
+// recentBadgeFetches remembers whose profile badges we've tried to fetch recently so people
+// without a badges list don't cause us to hit their relays on every profile view
+var recentBadgeFetches, _ = ristretto.NewCache(&ristretto.Config[nostr.PubKey, bool]{
+	NumCounters: 1e6,
+	MaxCost:     1 << 16,
+	BufferItems: 64,
+})
+
+func parseBadgeDefinition(event *nostr.Event) BadgeDefinition {
+	badge := BadgeDefinition{
+		Naddr: nip19.EncodeNaddr(event.PubKey, event.Kind, event.Tags.GetD(), nil),
+		Name:  event.Tags.GetD(),
+	}
+
+	if tag := event.Tags.Find("name"); tag != nil {
+		badge.Name = tag[1]
+	}
+	if tag := event.Tags.Find("description"); tag != nil {
+		badge.Description = tag[1]
+	}
+	if tag := event.Tags.Find("image"); tag != nil {
+		badge.Image = tag[1]
+	}
+	if tag := event.Tags.Find("thumb"); tag != nil {
+		badge.Thumb = tag[1]
+	} else {
+		badge.Thumb = badge.Image
+	}
+
+	return badge
+}
+
+// fetchBadgeDefinitions resolves all the "a" tags pointing to badge definitions in parallel,
+// this is used for both badge awards and profile badges lists
+func fetchBadgeDefinitions(ctx context.Context, tags nostr.Tags, maxBadges int) []BadgeDefinition {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	pointers := make([]nostr.EntityPointer, 0, maxBadges)
+	for tag := range tags.FindAll("a") {
+		if len(pointers) >= maxBadges {
+			break
+		}
+		if !strings.HasPrefix(tag[1], "30009:") {
+			continue
+		}
+		if pointer, err := nostr.EntityPointerFromTag(tag); err == nil {
+			pointers = append(pointers, pointer)
+		}
+	}
+
+	results := make([]*BadgeDefinition, len(pointers))
+	done := make(chan struct{}, len(pointers))
+	for i, pointer := range pointers {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			evt, _ := getEvent(ctx, nip19.EncodePointer(pointer), false)
+			if evt != nil {
+				badge := parseBadgeDefinition(evt)
+				results[i] = &badge
+			}
+		}()
+	}
+	for range pointers {
+		<-done
+	}
+
+	badges := make([]BadgeDefinition, 0, len(results))
+	for _, badge := range results {
+		if badge != nil {
+			badges = append(badges, *badge)
+		}
+	}
+	return badges
+}
+
+// badgeAwardees looks at the kind 8 awards we have stored locally for a given badge definition
+func badgeAwardees(ctx context.Context, definition *nostr.Event, maxAwardees int) []ContactInfo {
+	address := fmt.Sprintf("%d:%s:%s", definition.Kind, definition.PubKey.Hex(), definition.Tags.GetD())
+
+	pubkeys := make([]nostr.PubKey, 0, maxAwardees)
+out:
+	for award := range sys.Store.QueryEvents(nostr.Filter{
+		Kinds:   []nostr.Kind{8},
+		Authors: []nostr.PubKey{definition.PubKey},
+		Tags:    nostr.TagMap{"a": []string{address}},
+	}, DB_MAX_LIMIT) {
+		for tag := range award.Tags.FindAll("p") {
+			if len(pubkeys) >= maxAwardees {
+				break out
+			}
+			if pubkey, err := nostr.PubKeyFromHex(tag[1]); err == nil {
+				pubkeys = appendUnique(pubkeys, pubkey)
+			}
+		}
+	}
+
+	return fetchContacts(ctx, pubkeys)
+}
+
+// authorProfileBadges returns the badges an author has chosen to display in their profile,
+// if we don't have their kind 30008 list yet we fetch it in the background so it shows next time
+func authorProfileBadges(ctx context.Context, pubkey nostr.PubKey) []BadgeDefinition {
+	filter := nostr.Filter{
+		Kinds:   []nostr.Kind{30008},
+		Authors: []nostr.PubKey{pubkey},
+		Tags:    nostr.TagMap{"d": []string{"profile_badges"}},
+	}
+
+	for evt := range sys.Store.QueryEvents(filter, 1) {
+		return fetchBadgeDefinitions(ctx, evt.Tags, 24)
+	}
+
+	if _, fetchedRecently := recentBadgeFetches.Get(pubkey); fetchedRecently {
+		return nil
+	}
+	recentBadgeFetches.SetWithTTL(pubkey, true, 1, time.Hour)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		relays := sys.FetchOutboxRelays(ctx, pubkey, 3)
+		for len(relays) < 3 {
+			relays = appendUnique(relays, sys.FallbackRelays.Next())
+		}
+
+		for ie := range sys.Pool.FetchMany(ctx, relays, filter, nostr.SubscriptionOptions{Label: "profilebadges"}) {
+			sys.Store.SaveEvent(ie.Event)
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBadgeDefinition(t *testing.T) {
+	pubkey := nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+
+	badge := parseBadgeDefinition(&nostr.Event{
+		Kind:   30009,
+		PubKey: pubkey,
+		Tags: nostr.Tags{
+			{"d", "bravery"},
+			{"name", "Medal of Bravery"},
+			{"description", "Awarded to users demonstrating bravery"},
+			{"image", "https://nostr.academy/awards/bravery.png", "1024x1024"},
+			{"thumb", "https://nostr.academy/awards/bravery_256x256.png", "256x256"},
+		},
+	})
+	assert.Equal(t, "Medal of Bravery", badge.Name)
+	assert.Equal(t, "Awarded to users demonstrating bravery", badge.Description)
+	assert.Equal(t, "https://nostr.academy/awards/bravery.png", badge.Image)
+	assert.Equal(t, "https://nostr.academy/awards/bravery_256x256.png", badge.Thumb)
+	assert.Contains(t, badge.Naddr, "naddr1")
+
+	// the d tag is the name when there is nothing else and the image is also the thumb
+	badge = parseBadgeDefinition(&nostr.Event{
+		Kind:   30009,
+		PubKey: pubkey,
+		Tags: nostr.Tags{
+			{"d", "bravery"},
+			{"image", "https://nostr.academy/awards/bravery.png"},
+		},
+	})
+	assert.Equal(t, "bravery", badge.Name)
+	assert.Equal(t, "", badge.Description)
+	assert.Equal(t, "https://nostr.academy/awards/bravery.png", badge.Thumb)
+}
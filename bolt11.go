@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// decodeBolt11Amount reads the amount (in millisatoshis) from the human-readable part of a
+// bolt11 invoice, we don't need to decode the rest of the invoice for anything
+func decodeBolt11Amount(invoice string) (int64, error) {
+	invoice = strings.ToLower(strings.TrimSpace(invoice))
+	invoice = strings.TrimPrefix(invoice, "lightning:")
+
+	sep := strings.LastIndexByte(invoice, '1')
+	if sep == -1 || !strings.HasPrefix(invoice, "ln") {
+		return 0, fmt.Errorf("not a bolt11 invoice")
+	}
+	hrp := invoice[2:sep]
+
+	// skip the currency prefix (bc, tb, bcrt, sb etc)
+	i := 0
+	for i < len(hrp) && hrp[i] >= 'a' && hrp[i] <= 'z' {
+		i++
+	}
+	amount := hrp[i:]
+	if amount == "" {
+		return 0, fmt.Errorf("invoice has no amount")
+	}
+
+	multiplier := amount[len(amount)-1]
+	if multiplier >= '0' && multiplier <= '9' {
+		multiplier = 0
+	} else {
+		amount = amount[0 : len(amount)-1]
+	}
+
+	value, err := strconv.ParseInt(amount, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid invoice amount '%s': %w", amount, err)
+	}
+
+	// 1 btc = 100_000_000_000 msats
+	switch multiplier {
+	case 0:
+		return value * 100_000_000_000, nil
+	case 'm':
+		return value * 100_000_000, nil
+	case 'u':
+		return value * 100_000, nil
+	case 'n':
+		return value * 100, nil
+	case 'p':
+		if value%10 != 0 {
+			return 0, fmt.Errorf("invalid sub-millisatoshi amount")
+		}
+		return value / 10, nil
+	default:
+		return 0, fmt.Errorf("unknown invoice multiplier '%c'", multiplier)
+	}
+}
+
+// formatSats puts thousands separators on an amount of satoshis
+func formatSats(sats int64) string {
+	if sats < 0 {
+		return "-" + formatSats(-sats)
+	}
+	str := strconv.FormatInt(sats, 10)
+
+	var sb strings.Builder
+	for i, c := range str {
+		if i > 0 && (len(str)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
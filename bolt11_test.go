@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeBolt11Amount(t *testing.T) {
+	for invoice, expected := range map[string]int64{
+		"lnbc2500u1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypq": 250_000_000,
+		"lnbc20m1pvjluezhp58yjmdan79s6qqdhdzgynm4zwqd5d7xmw5fk98klysy043l2ahrqs":   2_000_000_000,
+		"LNBC10N1PJ8Z5S4PP5":  1_000,
+		"lntb1500n1pjqwerty":  150_000,
+		"lnbc20p1pjqwerty":    2,
+		"lightning:lnbc1m1pq": 100_000_000,
+	} {
+		msats, err := decodeBolt11Amount(invoice)
+		assert.NoError(t, err, invoice)
+		assert.Equal(t, expected, msats, invoice)
+	}
+
+	for _, invalid := range []string{
+		"lnbc1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5",
+		"lnbc25p1pjqwerty",
+		"nothing",
+	} {
+		_, err := decodeBolt11Amount(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestFormatSats(t *testing.T) {
+	assert.Equal(t, "0", formatSats(0))
+	assert.Equal(t, "999", formatSats(999))
+	assert.Equal(t, "1,000", formatSats(1000))
+	assert.Equal(t, "21,000,000", formatSats(21_000_000))
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+	"fiatjaf.com/nostr/nip52"
+)
+
+const CALENDAR_MAX_EVENTS = 200
+
+type CalendarEntry struct {
+	Naddr string
+	Kind31922Or31923Metadata
+	Event nostr.Event
+}
+
+// when formats the date (and time) of the entry in its own timezone
+func (ce CalendarEntry) when() string {
+	if ce.Event.Kind == 31922 {
+		return ce.Start.UTC().Format("Mon, 02 Jan 2006")
+	}
+
+	location, err := time.LoadLocation(ce.StartTzid)
+	if err != nil {
+		location = time.UTC
+	}
+	return ce.Start.In(location).Format("Mon, 02 Jan 2006 15:04") + " (" + getUTCOffset(location) + ")"
+}
+
+func (ce CalendarEntry) isPast() bool {
+	end := ce.End
+	if end.IsZero() {
+		end = ce.Start
+		if ce.Event.Kind == 31922 {
+			end = end.Add(time.Hour * 24)
+		}
+	}
+	return end.Before(time.Now())
+}
+
+func parseCalendarEntry(event nostr.Event) CalendarEntry {
+	ce := CalendarEntry{
+		Naddr:                    nip19.EncodeNaddr(event.PubKey, event.Kind, event.Tags.GetD(), nil),
+		Kind31922Or31923Metadata: Kind31922Or31923Metadata{CalendarEvent: nip52.ParseCalendarEvent(event)},
+		Event:                    event,
+	}
+	if ce.Title == "" {
+		if tag := event.Tags.Find("name"); tag != nil {
+			ce.Title = tag[1]
+		}
+	}
+	return ce
+}
+
+// fetchCalendarEntries loads the events referenced by a kind 31924 calendar and returns them split
+// into upcoming (soonest first) and past (most recent first)
+func fetchCalendarEntries(ctx context.Context, calendar *nostr.Event) (upcoming []CalendarEntry, past []CalendarEntry) {
+	pointers := make([]nostr.EntityPointer, 0, len(calendar.Tags))
+	relays := make([]string, 0, 6)
+	for tag := range calendar.Tags.FindAll("a") {
+		pointer, err := nostr.EntityPointerFromTag(tag)
+		if err != nil || (pointer.Kind != 31922 && pointer.Kind != 31923) {
+			continue
+		}
+		pointers = append(pointers, pointer)
+		relays = appendUnique(relays, pointer.Relays...)
+		if len(pointers) >= CALENDAR_MAX_EVENTS {
+			break
+		}
+	}
+	if len(pointers) == 0 {
+		return nil, nil
+	}
+
+	// a single filter that matches all the events, we'll check the exact addresses later
+	filter := nostr.Filter{
+		Kinds: []nostr.Kind{31922, 31923},
+		Tags:  nostr.TagMap{"d": make([]string, 0, len(pointers))},
+	}
+	for _, pointer := range pointers {
+		filter.Authors = appendUnique(filter.Authors, pointer.PublicKey)
+		filter.Tags["d"] = appendUnique(filter.Tags["d"], pointer.Identifier)
+	}
+
+	for _, url := range sys.FetchOutboxRelays(ctx, calendar.PubKey, 3) {
+		relays = appendUnique(relays, url)
+	}
+	for len(relays) < 3 {
+		relays = appendUnique(relays, sys.FallbackRelays.Next())
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, time.Second*4)
+	for ie := range sys.Pool.FetchMany(fetchCtx, relays, filter, nostr.SubscriptionOptions{Label: "calendar"}) {
+		sys.Store.SaveEvent(ie.Event)
+	}
+	cancel()
+
+	for evt := range sys.Store.QueryEvents(filter, DB_MAX_LIMIT) {
+		if !slices.ContainsFunc(pointers, func(p nostr.EntityPointer) bool {
+			return p.Kind == evt.Kind && p.PublicKey == evt.PubKey && p.Identifier == evt.Tags.GetD()
+		}) {
+			continue
+		}
+		if banned, _ := isEventBanned(evt.ID); banned {
+			continue
+		}
+		if banned, _ := isPubkeyBanned(evt.PubKey); banned {
+			continue
+		}
+
+		entry := parseCalendarEntry(evt)
+		if entry.isPast() {
+			past = append(past, entry)
+		} else {
+			upcoming = append(upcoming, entry)
+		}
+	}
+
+	slices.SortFunc(upcoming, func(a, b CalendarEntry) int { return a.Start.Compare(b.Start) })
+	slices.SortFunc(past, func(a, b CalendarEntry) int { return b.Start.Compare(a.Start) })
+	return upcoming, past
+}
+
+// icsEscape escapes text values according to RFC 5545
+func icsEscape(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(value)
+}
+
+// icsLine writes a content line folded at 75 octets as required by RFC 5545
+func icsLine(sb *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		// don't split utf-8 sequences
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		sb.WriteString(line[0:cut])
+		sb.WriteString("\r\n ")
+		line = line[cut:]
+
+		// the space at the start of the continuation lines counts too
+		limit = 74
+	}
+	sb.WriteString(line)
+	sb.WriteString("\r\n")
+}
+
+// decodeGeohash returns the coordinates of the center of a geohash cell
+func decodeGeohash(geohash string) (lat float64, lon float64, ok bool) {
+	const base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+	even := true
+	for _, c := range strings.ToLower(geohash) {
+		idx := strings.IndexRune(base32, c)
+		if idx == -1 {
+			return 0, 0, false
+		}
+		for bit := 4; bit >= 0; bit-- {
+			r := &latRange
+			if even {
+				r = &lonRange
+			}
+			mid := (r[0] + r[1]) / 2
+			if idx&(1<<bit) != 0 {
+				r[0] = mid
+			} else {
+				r[1] = mid
+			}
+			even = !even
+		}
+	}
+	if geohash == "" {
+		return 0, 0, false
+	}
+	return (latRange[0] + latRange[1]) / 2, (lonRange[0] + lonRange[1]) / 2, true
+}
+
+// icsDateTime formats a DTSTART or DTEND property in the event's own timezone, so recurring meetups
+// stay at the same local time across DST changes, or in UTC when it doesn't have a known timezone.
+// calendar apps know the IANA timezone names so we don't include the VTIMEZONE definitions.
+func icsDateTime(name string, t time.Time, tzid string) string {
+	if tzid != "" {
+		if location, err := time.LoadLocation(tzid); err == nil {
+			return name + ";TZID=" + tzid + ":" + t.In(location).Format("20060102T150405")
+		}
+	}
+	return name + ":" + t.UTC().Format("20060102T150405Z")
+}
+
+func writeICSEvent(sb *strings.Builder, ce CalendarEntry) {
+	url := "https://" + s.Domain + "/" + ce.Naddr
+
+	icsLine(sb, "BEGIN:VEVENT")
+	icsLine(sb, "UID:"+ce.Naddr+"@"+s.Domain)
+	icsLine(sb, "DTSTAMP:"+ce.Event.CreatedAt.Time().UTC().Format("20060102T150405Z"))
+	if ce.Event.Kind == 31922 {
+		icsLine(sb, "DTSTART;VALUE=DATE:"+ce.Start.UTC().Format("20060102"))
+		if !ce.End.IsZero() {
+			icsLine(sb, "DTEND;VALUE=DATE:"+ce.End.UTC().Format("20060102"))
+		}
+	} else {
+		icsLine(sb, icsDateTime("DTSTART", ce.Start, ce.StartTzid))
+		if !ce.End.IsZero() {
+			endTzid := ce.EndTzid
+			if endTzid == "" {
+				endTzid = ce.StartTzid
+			}
+			icsLine(sb, icsDateTime("DTEND", ce.End, endTzid))
+		}
+	}
+	icsLine(sb, "SUMMARY:"+icsEscape(ce.Title))
+	description := ce.Event.Content
+	if summary := ce.Event.Tags.Find("summary"); summary != nil && description == "" {
+		description = summary[1]
+	}
+	if description != "" {
+		icsLine(sb, "DESCRIPTION:"+icsEscape(description))
+	}
+	if len(ce.Locations) > 0 && ce.Locations[0] != "" {
+		icsLine(sb, "LOCATION:"+icsEscape(strings.Join(ce.Locations, ", ")))
+	}
+	if tag := ce.Event.Tags.Find("g"); tag != nil {
+		if lat, lon, ok := decodeGeohash(tag[1]); ok {
+			icsLine(sb, fmt.Sprintf("GEO:%.6f;%.6f", lat, lon))
+		}
+	}
+	if len(ce.Hashtags) > 0 {
+		categories := make([]string, len(ce.Hashtags))
+		for i, hashtag := range ce.Hashtags {
+			categories[i] = icsEscape(hashtag)
+		}
+		icsLine(sb, "CATEGORIES:"+strings.Join(categories, ","))
+	}
+	icsLine(sb, "URL:"+url)
+	icsLine(sb, "END:VEVENT")
+}
+
+// calendarICS builds an iCalendar document with the given events
+func calendarICS(name string, entries []CalendarEntry) string {
+	var sb strings.Builder
+	icsLine(&sb, "BEGIN:VCALENDAR")
+	icsLine(&sb, "VERSION:2.0")
+	icsLine(&sb, "PRODID:-//njump//"+s.Domain+"//EN")
+	icsLine(&sb, "CALSCALE:GREGORIAN")
+	if name != "" {
+		icsLine(&sb, "X-WR-CALNAME:"+icsEscape(name))
+	}
+	for _, entry := range entries {
+		writeICSEvent(&sb, entry)
+	}
+	icsLine(&sb, "END:VCALENDAR")
+	return sb.String()
+}
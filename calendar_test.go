@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeGeohash(t *testing.T) {
+	lat, lon, ok := decodeGeohash("u4pruydqqvj")
+	assert.True(t, ok)
+	assert.InDelta(t, 57.64911, lat, 0.0001)
+	assert.InDelta(t, 10.40744, lon, 0.0001)
+
+	_, _, ok = decodeGeohash("")
+	assert.False(t, ok)
+	_, _, ok = decodeGeohash("u4pa")
+	assert.False(t, ok)
+}
+
+func TestICSLine(t *testing.T) {
+	var sb strings.Builder
+	icsLine(&sb, "SUMMARY:"+icsEscape("meetup; bring snacks, drinks\nand friends"))
+	assert.Equal(t, `SUMMARY:meetup\; bring snacks\, drinks\nand friends`+"\r\n", sb.String())
+
+	sb.Reset()
+	icsLine(&sb, "DESCRIPTION:"+strings.Repeat("á", 100))
+	for _, line := range strings.Split(strings.TrimSuffix(sb.String(), "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75)
+	}
+	assert.Equal(t, "DESCRIPTION:"+strings.Repeat("á", 100), strings.ReplaceAll(strings.TrimSuffix(sb.String(), "\r\n"), "\r\n ", ""))
+}
+
+func TestICSDateTime(t *testing.T) {
+	at := time.Date(2024, 7, 1, 18, 30, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		tzid     string
+		expected string
+	}{
+		{"", "DTSTART:20240701T183000Z"},
+		{"America/Sao_Paulo", "DTSTART;TZID=America/Sao_Paulo:20240701T153000"},
+		{"Europe/Berlin", "DTSTART;TZID=Europe/Berlin:20240701T203000"},
+		{"Not/AZone", "DTSTART:20240701T183000Z"},
+	} {
+		assert.Equal(t, tc.expected, icsDateTime("DTSTART", at, tc.tzid), tc.tzid)
+	}
+}
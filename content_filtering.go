@@ -27,6 +27,22 @@ func isMaliciousBridged(pm sdk.ProfileMetadata) bool {
 	return strings.Contains(pm.NIP05, "rape.pet") || strings.Contains(pm.NIP05, "rape-pet")
 }
 
+// profileBlockedReason is the gatekeeping shared by everything that shows a profile: it returns why we
+// won't show it, or "" if it's fine, and deletes everything we have from the pubkeys it blocks
+func profileBlockedReason(ctx context.Context, profile sdk.ProfileMetadata) string {
+	if isMaliciousBridged(profile) {
+		deleteAllEventsFromPubKey(profile.PubKey)
+		log.Warn().Str("pubkey", profile.PubKey.Hex()).Msg("pubkey malicious bridged blocked")
+		return "profile is malicious"
+	}
+	if is, _ := isExplicitContent(ctx, profile.Picture); is {
+		deleteAllEventsFromPubKey(profile.PubKey)
+		log.Warn().Str("pubkey", profile.PubKey.Hex()).Msg("pubkey explicit content blocked")
+		return "profile is not allowed"
+	}
+	return ""
+}
+
 func hasProhibitedWordOrTag(event *nostr.Event) bool {
 	for _, tag := range event.Tags {
 		if len(tag) >= 2 && tag[0] == "t" && slices.Contains(pornTags, strings.ToLower(tag[1])) {
@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"html"
 	"html/template"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"fiatjaf.com/nostr"
@@ -44,6 +46,12 @@ type Data struct {
 	Kind30818Metadata        Kind30818Metadata
 	Nip51SetMetadata         Nip51SetMetadata
 	Kind9802Metadata         Kind9802Metadata
+	Kind9735Metadata         Kind9735Metadata
+	BadgeMetadata            BadgeMetadata
+	ListingMetadata          ListingMetadata
+	PollMetadata             PollMetadata
+	GitMetadata              GitMetadata
+	CalendarMetadata         CalendarMetadata
 	Kind39000Metadata        nip29.Group
 }
 
@@ -64,6 +72,46 @@ func extractContactsFromPTags(ctx context.Context, event *nostr.Event, maxContac
 		}
 	}
 
+	return fetchContacts(ctx, pubkeys)
+}
+
+const PROFILE_FETCH_CONCURRENCY = 10
+
+// fetchProfiles loads the profile metadata for many pubkeys at once, for places that would otherwise
+// call FetchProfileMetadata in a loop. only a few fetches run in parallel.
+func fetchProfiles(ctx context.Context, pubkeys []nostr.PubKey) map[nostr.PubKey]sdk.ProfileMetadata {
+	profiles := make(map[nostr.PubKey]sdk.ProfileMetadata, len(pubkeys))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, PROFILE_FETCH_CONCURRENCY)
+	seen := make(map[nostr.PubKey]struct{}, len(pubkeys))
+	for _, pubkey := range pubkeys {
+		if _, ok := seen[pubkey]; ok {
+			continue
+		}
+		seen[pubkey] = struct{}{}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			profile := sys.FetchProfileMetadata(ctx, pubkey)
+			mu.Lock()
+			profiles[pubkey] = profile
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return profiles
+}
+
+// fetchContacts loads the profile metadata for a list of pubkeys, keeping their order
+func fetchContacts(ctx context.Context, pubkeys []nostr.PubKey) []ContactInfo {
 	// Fetch all metadata in parallel
 	type result struct {
 		index   int
@@ -121,6 +169,9 @@ func grabData(ctx context.Context, code string) (Data, error) {
 		return Data{}, nil
 	}
 
+	// events we already had before we started recording them on save
+	recordRevision(*event)
+
 	ee := NewEnhancedEvent(ctx, *event)
 	ee.relays = sys.GetEventRelays(event.ID)
 
@@ -229,6 +280,7 @@ func grabData(ctx context.Context, code string) (Data, error) {
 			hostProfile := sys.FetchProfileMetadata(ctx, host.PubKey)
 			data.kind30311Metadata.Host = &hostProfile
 		}
+		loadLiveEventExtras(ctx, event, data.kind30311Metadata, ee.relays)
 	case 1311:
 		data.templateId = LiveEventMessage
 		data.content = event.Content
@@ -351,6 +403,133 @@ func grabData(ctx context.Context, code string) (Data, error) {
 			data.Kind9802Metadata.Comment = basicFormatting(comment[1], false, false, false)
 		}
 
+	case 9735:
+		data.templateId = Zap
+		if bolt11 := event.Tags.Find("bolt11"); bolt11 != nil {
+			if msats, err := decodeBolt11Amount(bolt11[1]); err == nil {
+				data.Kind9735Metadata.Amount = msats / 1000
+			}
+		}
+
+		// the zap request is embedded in the receipt, it tells us who sent it and why
+		var sender nostr.PubKey
+		if description := event.Tags.Find("description"); description != nil {
+			var zapRequest nostr.Event
+			if err := json.Unmarshal([]byte(description[1]), &zapRequest); err == nil && zapRequest.Kind == 9734 {
+				data.Kind9735Metadata.Comment = zapRequest.Content
+				if zapRequest.Tags.Find("anon") == nil {
+					sender = zapRequest.PubKey
+				}
+				if data.Kind9735Metadata.Amount == 0 {
+					if amount := zapRequest.Tags.Find("amount"); amount != nil {
+						if msats, err := strconv.ParseInt(amount[1], 10, 64); err == nil {
+							data.Kind9735Metadata.Amount = msats / 1000
+						}
+					}
+				}
+			}
+		} else if senderTag := event.Tags.Find("P"); senderTag != nil {
+			sender, _ = nostr.PubKeyFromHex(senderTag[1])
+		}
+
+		var recipient nostr.PubKey
+		if recipientTag := event.Tags.Find("p"); recipientTag != nil {
+			recipient, _ = nostr.PubKeyFromHex(recipientTag[1])
+		}
+
+		if atag := event.Tags.Find("a"); atag != nil {
+			if pointer, err := nostr.EntityPointerFromTag(atag); err == nil {
+				data.Kind9735Metadata.ZappedEvent = nip19.EncodePointer(pointer)
+			}
+		} else if etag := event.Tags.Find("e"); etag != nil {
+			if pointer, err := nostr.EventPointerFromTag(etag); err == nil {
+				data.Kind9735Metadata.ZappedEvent = nip19.EncodePointer(pointer)
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+		defer cancel()
+		wg := sync.WaitGroup{}
+		if sender != nostr.ZeroPK {
+			wg.Add(1)
+			go func() {
+				metadata := sys.FetchProfileMetadata(ctx, sender)
+				data.Kind9735Metadata.Sender = &metadata
+				wg.Done()
+			}()
+		}
+		if recipient != nostr.ZeroPK {
+			wg.Add(1)
+			go func() {
+				metadata := sys.FetchProfileMetadata(ctx, recipient)
+				data.Kind9735Metadata.Recipient = &metadata
+				wg.Done()
+			}()
+		}
+		wg.Wait()
+
+	case 8, 30008, 30009:
+		data.templateId = Badge
+		switch event.Kind {
+		case 30009:
+			data.BadgeMetadata.Badge = parseBadgeDefinition(event)
+			data.BadgeMetadata.Awardees = badgeAwardees(ctx, event, 50)
+		case 8:
+			if badges := fetchBadgeDefinitions(ctx, event.Tags, 1); len(badges) > 0 {
+				data.BadgeMetadata.Badge = badges[0]
+			}
+			data.BadgeMetadata.Awardees = extractContactsFromPTags(ctx, event, 50)
+		case 30008:
+			data.BadgeMetadata.Badges = fetchBadgeDefinitions(ctx, event.Tags, 50)
+		}
+
+	case 30017, 30018, 30402:
+		data.templateId = Listing
+		switch event.Kind {
+		case 30017:
+			data.ListingMetadata = parseNip15Stall(event)
+			data.ListingMetadata.Products = stallProducts(event, 50)
+			data.content = data.ListingMetadata.Summary
+		case 30018:
+			listing, stallID := parseNip15Product(event)
+			attachStallToProduct(ctx, &listing, event.PubKey, stallID, relaysForNip19)
+			data.ListingMetadata = listing
+			data.content = listing.Summary
+		case 30402:
+			data.ListingMetadata = parseNip99Listing(event)
+			data.content = event.Content
+		}
+
+	case 1068:
+		data.templateId = Poll
+		data.content = event.Content
+		data.PollMetadata = parsePoll(event)
+		tallyPoll(ctx, event, &data.PollMetadata)
+
+	case 30617, 1617, 1621:
+		data.templateId = Git
+		data.content = event.Content
+		switch event.Kind {
+		case 30617:
+			data.GitMetadata.Repository = parseGitRepository(ctx, event)
+			data.GitMetadata.Issues, data.GitMetadata.Patches = gitRepositoryActivity(ctx, event, data.GitMetadata.Repository, 20)
+		case 1617:
+			data.content = ""
+			data.GitMetadata.Diff = renderPatchDiff(event.Content)
+			loadGitTarget(ctx, event, &data.GitMetadata, ee.relays)
+		case 1621:
+			loadGitTarget(ctx, event, &data.GitMetadata, ee.relays)
+		}
+
+	case 31924:
+		data.templateId = CalendarCollection
+		data.content = event.Content
+		data.CalendarMetadata.Title = event.Tags.GetD()
+		if titleTag := event.Tags.Find("title"); titleTag != nil {
+			data.CalendarMetadata.Title = titleTag[1]
+		}
+		data.CalendarMetadata.Upcoming, data.CalendarMetadata.Past = fetchCalendarEntries(ctx, event)
+
 	default:
 		data.templateId = Other
 	}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"net/url"
 	"regexp"
 	"strings"
 	"unicode"
@@ -32,6 +33,15 @@ func normalizeDTag(input string) string {
 	return result
 }
 
+// wikilink is a djot link to the topic page, marked when we don't know of any article for it yet
+func wikilink(display string, normalized string) string {
+	class := ".wikilink"
+	if !wikiTopicExists(normalized) {
+		class += " .wikilink-missing"
+	}
+	return `[` + display + `](/w/` + url.PathEscape(normalized) + `){` + class + ` title="wikilink to “` + normalized + `”"}`
+}
+
 func processWikilinks(djotInput string) string {
 	lines := strings.Split(djotInput, "\n")
 
@@ -58,8 +68,7 @@ func processWikilinks(djotInput string) string {
 			return match
 		}
 
-		normalized := normalizeDTag(display)
-		return `[` + display + `]{.wikilink title="wikilink to “` + normalized + `”"}`
+		return wikilink(display, normalizeDTag(display))
 	})
 
 	djotInput = explicitPattern.ReplaceAllStringFunc(djotInput, func(match string) string {
@@ -71,8 +80,7 @@ func processWikilinks(djotInput string) string {
 			return match
 		}
 
-		normalized := normalizeDTag(ref)
-		return `[` + display + `]{.wikilink title="wikilink to “` + normalized + `”"}`
+		return wikilink(display, normalizeDTag(ref))
 	})
 
 	return djotInput
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"github.com/dgraph-io/ristretto"
+)
+
+type Engagement struct {
+	Reactions int
+	TopEmoji  []string
+	Reposts   int
+	Zaps      int
+	ZapSats   int64
+	Replies   int
+}
+
+var engagementCache, _ = ristretto.NewCache(&ristretto.Config[string, Engagement]{
+	NumCounters: 1e5,
+	MaxCost:     1 << 14,
+	BufferItems: 64,
+})
+
+func (e Engagement) isEmpty() bool {
+	return e.Reactions == 0 && e.Reposts == 0 && e.Zaps == 0 && e.Replies == 0
+}
+
+func pluralize(n int, singular string, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+// summary is a plain text line like "12 reactions · 3 reposts · 2,100 sats zapped · 4 replies",
+// suitable for places where we can't render emojis
+func (e Engagement) summary() string {
+	parts := make([]string, 0, 4)
+	if e.Reactions > 0 {
+		parts = append(parts, pluralize(e.Reactions, "reaction", "reactions"))
+	}
+	if e.Reposts > 0 {
+		parts = append(parts, pluralize(e.Reposts, "repost", "reposts"))
+	}
+	if e.Zaps > 0 {
+		parts = append(parts, formatSats(e.ZapSats)+" sats zapped")
+	}
+	if e.Replies > 0 {
+		parts = append(parts, pluralize(e.Replies, "reply", "replies"))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// iconSummary is a shorter version of summary for the preview images, which can render emojis
+func (e Engagement) iconSummary() string {
+	parts := make([]string, 0, 4)
+	if e.Reactions > 0 {
+		emoji := "❤️"
+		if len(e.TopEmoji) > 0 {
+			emoji = e.TopEmoji[0]
+		}
+		parts = append(parts, fmt.Sprintf("%s %d", emoji, e.Reactions))
+	}
+	if e.Reposts > 0 {
+		parts = append(parts, fmt.Sprintf("🔁 %d", e.Reposts))
+	}
+	if e.Zaps > 0 {
+		parts = append(parts, "⚡ "+formatSats(e.ZapSats))
+	}
+	if e.Replies > 0 {
+		parts = append(parts, fmt.Sprintf("💬 %d", e.Replies))
+	}
+	return strings.Join(parts, "   ")
+}
+
+// lastETagIs checks if the last "e" tag points to the given id, which is how reactions,
+// reposts and zaps mark their target when they also tag other events in the thread
+func lastETagIs(tags nostr.Tags, id string) bool {
+	var last nostr.Tag
+	for tag := range tags.FindAll("e") {
+		last = tag
+	}
+	return last != nil && last[1] == id
+}
+
+// fetchEngagement counts reactions, reposts, zaps and replies to an event using the relays
+// the event was seen on and our local store. results are cached for a few minutes.
+func fetchEngagement(ctx context.Context, event *nostr.Event) Engagement {
+	id := event.ID.Hex()
+	if cached, ok := engagementCache.Get(id); ok {
+		return cached
+	}
+
+	filter := nostr.Filter{
+		Kinds: []nostr.Kind{1, 6, 7, 16, 1111, 9735},
+		Tags:  nostr.TagMap{"e": []string{id}},
+	}
+
+	relays := sys.GetEventRelays(event.ID)
+	if len(relays) > 0 {
+		ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+		for ie := range sys.Pool.FetchMany(ctx, relays, filter, nostr.SubscriptionOptions{Label: "engagement"}) {
+			sys.Store.SaveEvent(ie.Event)
+		}
+		cancel()
+	}
+
+	// zap receipts only count if they were signed by the recipient's lightning provider (NIP-57)
+	zappers := make(map[nostr.PubKey]nostr.PubKey)
+	isValidZap := func(receipt nostr.Event) bool {
+		tag := receipt.Tags.Find("p")
+		if tag == nil {
+			return false
+		}
+		recipient, err := nostr.PubKeyFromHex(tag[1])
+		if err != nil {
+			return false
+		}
+		zapper, loaded := zappers[recipient]
+		if !loaded {
+			zapper, _ = fetchZapper(ctx, recipient)
+			zappers[recipient] = zapper
+		}
+		return zapper != nostr.ZeroPK && receipt.PubKey == zapper
+	}
+
+	engagement := countEngagement(event.ID, sys.Store.QueryEvents(filter, DB_MAX_LIMIT*4), isValidZap)
+	engagementCache.SetWithTTL(id, engagement, 1, time.Minute*5)
+	return engagement
+}
+
+// countEngagement adds up the reactions, reposts, zaps and direct replies to the event with the given id
+func countEngagement(id nostr.ID, events iter.Seq[nostr.Event], isValidZap func(receipt nostr.Event) bool) Engagement {
+	var engagement Engagement
+	emojis := make(map[string]int)
+	for evt := range events {
+		if banned, _ := isPubkeyBanned(evt.PubKey); banned {
+			continue
+		}
+
+		switch evt.Kind {
+		case 7:
+			if !lastETagIs(evt.Tags, id.Hex()) {
+				continue
+			}
+			switch evt.Content {
+			case "-":
+				// downvotes aren't engagement we want to show
+				continue
+			case "+", "":
+				emojis["❤️"]++
+			default:
+				emojis[evt.Content]++
+			}
+			engagement.Reactions++
+		case 6, 16:
+			if lastETagIs(evt.Tags, id.Hex()) {
+				engagement.Reposts++
+			}
+		case 9735:
+			if !lastETagIs(evt.Tags, id.Hex()) || !isValidZap(evt) {
+				continue
+			}
+			if tag := evt.Tags.Find("bolt11"); tag != nil {
+				if msats, err := decodeBolt11Amount(tag[1]); err == nil {
+					engagement.Zaps++
+					engagement.ZapSats += msats / 1000
+				}
+			}
+		case 1, 1111:
+			// only count direct replies
+			if parent, ok := NewEnhancedEventWithoutMetadata(evt).getParent().(nostr.EventPointer); ok && parent.ID == id {
+				engagement.Replies++
+			}
+		}
+	}
+
+	engagement.TopEmoji = make([]string, 0, len(emojis))
+	for emoji := range emojis {
+		// custom emojis (":shortcode:") can't be displayed without their tags
+		if len([]rune(emoji)) <= 4 {
+			engagement.TopEmoji = append(engagement.TopEmoji, emoji)
+		}
+	}
+	slices.SortFunc(engagement.TopEmoji, func(a, b string) int {
+		if emojis[a] == emojis[b] {
+			return strings.Compare(a, b)
+		}
+		return emojis[b] - emojis[a]
+	})
+	if len(engagement.TopEmoji) > 3 {
+		engagement.TopEmoji = engagement.TopEmoji[0:3]
+	}
+
+	return engagement
+}
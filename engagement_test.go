@@ -0,0 +1,68 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngagementSummary(t *testing.T) {
+	assert.Equal(t, "", Engagement{}.summary())
+	assert.Equal(t, "1 reaction · 1 repost · 21 sats zapped · 1 reply",
+		Engagement{Reactions: 1, Reposts: 1, Zaps: 1, ZapSats: 21, Replies: 1}.summary())
+	assert.Equal(t, "12 reactions · 2,100 sats zapped · 4 replies",
+		Engagement{Reactions: 12, Zaps: 3, ZapSats: 2100, Replies: 4}.summary())
+
+	assert.Equal(t, "", Engagement{}.iconSummary())
+	assert.Equal(t, "❤️ 2   🔁 3", Engagement{Reactions: 2, Reposts: 3}.iconSummary())
+	assert.Equal(t, "🤙 5   ⚡ 1,000   💬 2", Engagement{Reactions: 5, TopEmoji: []string{"🤙", "❤️"}, Zaps: 1, ZapSats: 1000, Replies: 2}.iconSummary())
+}
+
+func TestLastETagIs(t *testing.T) {
+	assert.True(t, lastETagIs(nostr.Tags{{"e", "root"}, {"p", "x"}, {"e", "target"}}, "target"))
+	assert.False(t, lastETagIs(nostr.Tags{{"e", "target"}, {"e", "other"}}, "target"))
+	assert.False(t, lastETagIs(nostr.Tags{{"p", "target"}}, "target"))
+	assert.False(t, lastETagIs(nil, "target"))
+}
+
+func TestCountEngagement(t *testing.T) {
+	setupTestStores(t)
+
+	id, _ := nostr.IDFromHex("4ccd65db0428cee600ee976d6cfcc7c5cee7438594a3179148d1f4d970c8a5f3")
+	other, _ := nostr.IDFromHex("97c70a44366a6535c145b333f973ea86dfdc2d7a99da618c40c64705ad98e322")
+	zapper := nostr.MustPubKeyFromHex("7bdef7be22dd8e59f4600e044aa53a1cf975a9dc7d27df5833bc77db784a5805")
+	impostor := nostr.MustPubKeyFromHex("97c70a44366a6535c145b333f973ea86dfdc2d7a99da618c40c64705ad98e322")
+	bolt11 := "lnbc2500u1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypq"
+
+	events := []nostr.Event{
+		{Kind: 7, Content: "+", Tags: nostr.Tags{{"e", id.Hex()}}},
+		{Kind: 7, Content: "🤙", Tags: nostr.Tags{{"e", id.Hex()}}},
+		{Kind: 7, Content: "🤙", Tags: nostr.Tags{{"e", id.Hex()}}},
+		{Kind: 7, Content: "-", Tags: nostr.Tags{{"e", id.Hex()}}},
+		{Kind: 7, Content: "+", Tags: nostr.Tags{{"e", id.Hex()}, {"e", other.Hex()}}},
+		{Kind: 6, Tags: nostr.Tags{{"e", id.Hex()}}},
+		{Kind: 16, Tags: nostr.Tags{{"e", other.Hex()}}},
+		{Kind: 9735, PubKey: zapper, Tags: nostr.Tags{{"e", id.Hex()}, {"bolt11", bolt11}}},
+		{Kind: 9735, PubKey: impostor, Tags: nostr.Tags{{"e", id.Hex()}, {"bolt11", bolt11}}},
+		{Kind: 1, Tags: nostr.Tags{{"e", id.Hex(), "", "root"}}},
+		{Kind: 1, Tags: nostr.Tags{{"e", id.Hex(), "", "root"}, {"e", other.Hex(), "", "reply"}}},
+	}
+
+	engagement := countEngagement(id, slices.Values(events), func(receipt nostr.Event) bool { return receipt.PubKey == zapper })
+	assert.Equal(t, 3, engagement.Reactions, "downvotes and reactions to other events don't count")
+	assert.Equal(t, []string{"🤙", "❤️"}, engagement.TopEmoji)
+	assert.Equal(t, 1, engagement.Reposts)
+	assert.Equal(t, 1, engagement.Zaps, "receipts not signed by the zapper don't count")
+	assert.Equal(t, int64(250_000), engagement.ZapSats)
+	assert.Equal(t, 1, engagement.Replies, "only direct replies count")
+}
+
+func TestLNURLPURL(t *testing.T) {
+	assert.Equal(t, "https://getalby.com/.well-known/lnurlp/njump", lnurlpURL("njump@GetAlby.com"))
+	assert.Equal(t, "", lnurlpURL(""))
+	assert.Equal(t, "", lnurlpURL("njump"))
+	assert.Equal(t, "", lnurlpURL("@getalby.com"))
+	assert.Equal(t, "", lnurlpURL("a/b@getalby.com"))
+}
@@ -14,6 +14,7 @@ import (
 	"fiatjaf.com/nostr/nip19"
 	"fiatjaf.com/nostr/nip22"
 	"fiatjaf.com/nostr/nip73"
+	"fiatjaf.com/nostr/nip92"
 	"fiatjaf.com/nostr/sdk"
 	"github.com/texttheater/golang-levenshtein/levenshtein"
 )
@@ -118,6 +119,10 @@ func (ee EnhancedEvent) Preview() template.HTML {
 }
 
 func (ee EnhancedEvent) RssTitle() string {
+	if ee.subject != "" {
+		return ee.subject
+	}
+
 	regex := regexp.MustCompile(`(?i)<br\s?/?>`)
 	replacedString := regex.ReplaceAllString(string(ee.Preview()), " ")
 	words := strings.Fields(replacedString)
@@ -150,6 +155,9 @@ func (ee EnhancedEvent) RssTitle() string {
 
 func (ee EnhancedEvent) RssContent() string {
 	content := ee.Event.Content
+	if ee.Kind == 30023 {
+		return mdToHTML(content, false)
+	}
 	content = basicFormatting(html.EscapeString(content), true, false, false)
 	content = renderQuotesAsHTML(context.Background(), content, false)
 	if parent := ee.getParent(); parent != nil {
@@ -165,6 +173,12 @@ func (ee EnhancedEvent) RssContent() string {
 }
 
 func (ee EnhancedEvent) Thumb() string {
+	for _, entry := range nip92.ParseTags(ee.Tags) {
+		if imageExtensionMatcher.MatchString(entry.URL) {
+			return entry.URL
+		}
+	}
+
 	imgRegex := regexp.MustCompile(`(https?://[^\s]+\.(?:png|jpe?g|gif|bmp|svg)(?:/[^\s]*)?)`)
 	matches := imgRegex.FindAllStringSubmatch(ee.Event.Content, -1)
 	if len(matches) > 0 {
@@ -174,6 +188,19 @@ func (ee EnhancedEvent) Thumb() string {
 	return ""
 }
 
+// Cover is the image set on articles and other long-form events
+func (ee EnhancedEvent) Cover() string {
+	if tag := ee.Tags.Find("image"); tag != nil {
+		return tag[1]
+	}
+	return ""
+}
+
+// CoverMimeType is used for the feed enclosures
+func (ee EnhancedEvent) CoverMimeType() string {
+	return coverMimeType(ee.Cover())
+}
+
 func (ee EnhancedEvent) Npub() string {
 	return nip19.EncodeNpub(ee.Event.PubKey)
 }
@@ -187,6 +214,14 @@ func (ee EnhancedEvent) Nevent() string {
 	return nip19.EncodeNevent(ee.Event.ID, ee.relays, ee.Event.PubKey)
 }
 
+// Code is the naddr for addressable events and the nevent for everything else
+func (ee EnhancedEvent) Code() string {
+	if ee.Kind >= 30000 && ee.Kind < 40000 {
+		return nip19.EncodeNaddr(ee.Event.PubKey, ee.Event.Kind, ee.Tags.GetD(), ee.relays)
+	}
+	return ee.Nevent()
+}
+
 func (ee EnhancedEvent) CreatedAtStr() string {
 	return time.Unix(int64(ee.Event.CreatedAt), 0).Format("2006-01-02 15:04:05 MST")
 }
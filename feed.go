@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fiatjaf.com/nostr"
+)
+
+// JSONFeed is a JSON Feed 1.1 document, see https://www.jsonfeed.org/version/1.1/
+type JSONFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url,omitempty"`
+	FeedURL     string           `json:"feed_url,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Icon        string           `json:"icon,omitempty"`
+	NextURL     string           `json:"next_url,omitempty"`
+	Authors     []JSONFeedAuthor `json:"authors,omitempty"`
+	Items       []JSONFeedItem   `json:"items"`
+}
+
+type JSONFeedAuthor struct {
+	Name   string `json:"name,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+type JSONFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url"`
+	Title         string               `json:"title,omitempty"`
+	ContentHTML   string               `json:"content_html"`
+	Summary       string               `json:"summary,omitempty"`
+	Image         string               `json:"image,omitempty"`
+	DatePublished string               `json:"date_published"`
+	Authors       []JSONFeedAuthor     `json:"authors,omitempty"`
+	Tags          []string             `json:"tags,omitempty"`
+	Attachments   []JSONFeedAttachment `json:"attachments,omitempty"`
+}
+
+type JSONFeedAttachment struct {
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+}
+
+// feedKinds reads the ?kinds= parameter that restricts feeds to some kinds, like ?kinds=30023 for articles only
+func feedKinds(r *http.Request) []nostr.Kind {
+	var kinds []nostr.Kind
+	for _, k := range strings.Split(r.URL.Query().Get("kinds"), ",") {
+		kind, err := strconv.ParseUint(strings.TrimSpace(k), 10, 16)
+		if err != nil {
+			continue
+		}
+		if !slices.Contains(kinds, nostr.Kind(kind)) {
+			kinds = append(kinds, nostr.Kind(kind))
+		}
+	}
+	return kinds
+}
+
+// feedTitle is how we call the items of a feed restricted to the given kinds
+func feedTitle(kinds []nostr.Kind) string {
+	if slices.Equal(kinds, []nostr.Kind{30023}) {
+		return "articles"
+	}
+	return ""
+}
+
+// coverMimeType guesses the type of a cover image from its extension
+func coverMimeType(cover string) string {
+	if typ := imageTypeFromURL(cover); typ != "" {
+		return typ
+	}
+	// most covers without an extension are jpegs served by image hosts
+	return "image/jpeg"
+}
+
+// jsonFeedItems lists the events as feed items, the author is only included in the items when we have
+// their profile, otherwise the items inherit the authors of the feed, if any
+func jsonFeedItems(events []EnhancedEvent) []JSONFeedItem {
+	items := make([]JSONFeedItem, 0, len(events))
+	for _, ee := range events {
+		link := "https://" + s.Domain + "/" + ee.Code()
+		item := JSONFeedItem{
+			ID:            link,
+			URL:           link,
+			Title:         ee.RssTitle(),
+			ContentHTML:   ee.RssContent(),
+			Summary:       ee.summary,
+			Image:         ee.Cover(),
+			DatePublished: ee.ModifiedAtStr(),
+		}
+		if ee.author.Event != nil {
+			item.Authors = []JSONFeedAuthor{
+				{
+					Name:   ee.author.ShortName(),
+					URL:    "https://" + s.Domain + "/" + ee.Npub(),
+					Avatar: ee.author.Picture,
+				},
+			}
+		}
+		for tag := range ee.Tags.FindAll("t") {
+			item.Tags = appendUnique(item.Tags, tag[1])
+		}
+		if item.Image != "" {
+			item.Attachments = []JSONFeedAttachment{{URL: item.Image, MimeType: coverMimeType(item.Image)}}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func renderJSONFeed(w http.ResponseWriter, feed JSONFeed) error {
+	feed.Version = "https://jsonfeed.org/version/1.1"
+	w.Header().Add("content-type", "application/feed+json")
+	return json.NewEncoder(w).Encode(feed)
+}
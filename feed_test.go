@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeedKinds(t *testing.T) {
+	for _, tc := range []struct {
+		query    string
+		expected []nostr.Kind
+	}{
+		{"", nil},
+		{"?kinds=30023", []nostr.Kind{30023}},
+		{"?kinds=1,%2030023,1", []nostr.Kind{1, 30023}},
+		{"?kinds=abc,-1,99999999,20", []nostr.Kind{20}},
+	} {
+		r := httptest.NewRequest("GET", "/npub1x.rss"+tc.query, nil)
+		assert.Equal(t, tc.expected, feedKinds(r), tc.query)
+	}
+}
+
+func TestFeedTitle(t *testing.T) {
+	assert.Equal(t, "articles", feedTitle([]nostr.Kind{30023}))
+	assert.Equal(t, "", feedTitle([]nostr.Kind{1, 30023}))
+	assert.Equal(t, "", feedTitle(nil))
+}
+
+func TestCoverMimeType(t *testing.T) {
+	assert.Equal(t, "image/png", coverMimeType("https://example.com/cover.png?size=large"))
+	assert.Equal(t, "image/jpeg", coverMimeType("https://example.com/cover"))
+	assert.Equal(t, "image/jpeg", coverMimeType("https://example.com/cover.mp4"))
+}
+
+func TestJSONFeedItems(t *testing.T) {
+	event := nostr.Event{
+		Kind:      30023,
+		PubKey:    nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"),
+		CreatedAt: 1700000000,
+		Tags: nostr.Tags{
+			{"d", "hello"},
+			{"title", "Hello"},
+			{"image", "https://example.com/cover.png"},
+			{"t", "nostr"},
+			{"t", "nostr"},
+		},
+		Content: "hi **there**",
+	}
+
+	// without the profile loaded the items inherit the feed authors
+	items := jsonFeedItems([]EnhancedEvent{NewEnhancedEventWithoutMetadata(event)})
+	assert.Len(t, items, 1)
+	assert.Equal(t, "Hello", items[0].Title)
+	assert.Contains(t, items[0].ContentHTML, "<strong>there</strong>")
+	assert.Equal(t, []string{"nostr"}, items[0].Tags)
+	assert.Equal(t, []JSONFeedAttachment{{URL: "https://example.com/cover.png", MimeType: "image/png"}}, items[0].Attachments)
+	assert.Nil(t, items[0].Authors)
+
+	ee := NewEnhancedEventWithoutMetadata(event)
+	ee.author = sdk.ProfileMetadata{PubKey: event.PubKey, Name: "fiatjaf", Event: &nostr.Event{Kind: 0}}
+	items = jsonFeedItems([]EnhancedEvent{ee})
+	assert.Len(t, items[0].Authors, 1)
+	assert.Equal(t, "fiatjaf", items[0].Authors[0].Name)
+}
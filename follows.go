@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"iter"
+	"sync"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"github.com/dgraph-io/ristretto"
+)
+
+// FOLLOWERS_MAX_COUNT is how many follow lists we download when no relay answers our COUNT
+const FOLLOWERS_MAX_COUNT = 300
+
+type FollowCounts struct {
+	Following int
+	Followers int
+
+	// FollowersCapped is set when we had to count the follow lists ourselves and stopped early
+	FollowersCapped bool
+}
+
+var followCountsCache, _ = ristretto.NewCache(&ristretto.Config[nostr.PubKey, FollowCounts]{
+	NumCounters: 1e5,
+	MaxCost:     1 << 14,
+	BufferItems: 64,
+})
+
+// followersStr is the followers count as we display it, we can't know the exact number
+// for very popular people when we had to stop counting at some point
+func (fc FollowCounts) followersStr() string {
+	if fc.FollowersCapped {
+		return formatSats(int64(fc.Followers)) + "+"
+	}
+	return formatSats(int64(fc.Followers))
+}
+
+// fetchFollowCounts counts how many people a pubkey follows and how many follow it.
+// followers are counted by the follow list relays that support NIP-45, only when none of them
+// do we download a few follow lists and count them. results are cached for a while.
+func fetchFollowCounts(ctx context.Context, pubkey nostr.PubKey) FollowCounts {
+	if cached, ok := followCountsCache.Get(pubkey); ok {
+		return cached
+	}
+
+	var counts FollowCounts
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+		defer cancel()
+		counts.Following = len(sys.FetchFollowList(ctx, pubkey).Items)
+	}()
+
+	filter := nostr.Filter{
+		Kinds: []nostr.Kind{3},
+		Tags:  nostr.TagMap{"p": []string{pubkey.Hex()}},
+	}
+	if followers, ok := countFollowers(ctx, filter); ok {
+		counts.Followers = followers
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+		defer cancel()
+
+		filter.Limit = FOLLOWERS_MAX_COUNT
+		counts.Followers = countAuthors(func(yield func(nostr.Event) bool) {
+			for ie := range sys.Pool.FetchMany(ctx, sys.FollowListRelays.URLs, filter, nostr.SubscriptionOptions{Label: "followers"}) {
+				if !yield(ie.Event) {
+					return
+				}
+			}
+		}, FOLLOWERS_MAX_COUNT)
+		counts.FollowersCapped = counts.Followers >= FOLLOWERS_MAX_COUNT
+	}
+
+	wg.Wait()
+
+	followCountsCache.SetWithTTL(pubkey, counts, 1, time.Hour)
+	return counts
+}
+
+// countFollowers sends a NIP-45 COUNT to all the follow list relays at the same time and takes
+// the biggest answer, since each relay only knows about the lists it has. ok is false when no relay
+// answered, which is what happens with relays that don't support COUNT.
+func countFollowers(ctx context.Context, filter nostr.Filter) (followers int, ok bool) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*2)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, url := range sys.FollowListRelays.URLs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			relay, err := sys.Pool.EnsureRelay(url)
+			if err != nil {
+				return
+			}
+			count, _, err := relay.Count(ctx, filter, nostr.SubscriptionOptions{Label: "followers"})
+			if err != nil {
+				return
+			}
+
+			mu.Lock()
+			followers = max(followers, int(count))
+			ok = true
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return followers, ok
+}
+
+// countAuthors counts the distinct authors of the given events, it stops at max
+func countAuthors(events iter.Seq[nostr.Event], max int) int {
+	authors := make(map[nostr.PubKey]struct{}, 100)
+	for evt := range events {
+		authors[evt.PubKey] = struct{}{}
+		if len(authors) >= max {
+			break
+		}
+	}
+	return len(authors)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountAuthors(t *testing.T) {
+	a := nostr.PubKey{1}
+	b := nostr.PubKey{2}
+	c := nostr.PubKey{3}
+	events := []nostr.Event{{PubKey: a}, {PubKey: b}, {PubKey: a}, {PubKey: c}, {PubKey: b}}
+
+	assert.Equal(t, 0, countAuthors(slices.Values([]nostr.Event{}), 10))
+	assert.Equal(t, 3, countAuthors(slices.Values(events), 10))
+	assert.Equal(t, 2, countAuthors(slices.Values(events), 2))
+}
+
+func TestFollowersStr(t *testing.T) {
+	assert.Equal(t, "0", FollowCounts{}.followersStr())
+	assert.Equal(t, "1,234", FollowCounts{Followers: 1234}.followersStr())
+	assert.Equal(t, "300", FollowCounts{Followers: 300}.followersStr())
+	assert.Equal(t, "300+", FollowCounts{Followers: 300, FollowersCapped: true}.followersStr())
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"html"
+	"html/template"
+	"slices"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+var gitStatusKinds = []nostr.Kind{1630, 1631, 1632, 1633}
+
+func gitStatusName(kind nostr.Kind, targetKind nostr.Kind) string {
+	switch kind {
+	case 1631:
+		if targetKind == 1617 {
+			return "Applied"
+		}
+		return "Resolved"
+	case 1632:
+		return "Closed"
+	case 1633:
+		return "Draft"
+	default:
+		return "Open"
+	}
+}
+
+func parseGitRepository(ctx context.Context, event *nostr.Event) GitRepository {
+	repo := GitRepository{
+		Naddr: nip19.EncodeNaddr(event.PubKey, event.Kind, event.Tags.GetD(), nil),
+		ID:    event.Tags.GetD(),
+		Name:  event.Tags.GetD(),
+	}
+
+	maintainers := []nostr.PubKey{event.PubKey}
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+
+		switch tag[0] {
+		case "name":
+			repo.Name = tag[1]
+		case "description":
+			repo.Description = tag[1]
+		case "web":
+			repo.Web = append(repo.Web, tag[1:]...)
+		case "clone":
+			repo.Clone = append(repo.Clone, tag[1:]...)
+		case "relays":
+			for _, url := range tag[1:] {
+				repo.Relays = appendUnique(repo.Relays, nostr.NormalizeURL(url))
+			}
+		case "maintainers":
+			for _, pk := range tag[1:] {
+				if pubkey, err := nostr.PubKeyFromHex(pk); err == nil {
+					maintainers = appendUnique(maintainers, pubkey)
+				}
+			}
+		case "t":
+			repo.Hashtags = append(repo.Hashtags, tag[1])
+		}
+	}
+	repo.Maintainers = fetchContacts(ctx, maintainers)
+
+	return repo
+}
+
+// gitQuery fetches events from the repository relays into our local store and then returns
+// everything we have locally for the filter, newest first
+func gitQuery(ctx context.Context, relays []string, filter nostr.Filter, label string) []nostr.Event {
+	if len(relays) > 0 {
+		ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+		for ie := range sys.Pool.FetchMany(ctx, relays, filter, nostr.SubscriptionOptions{Label: label}) {
+			sys.Store.SaveEvent(ie.Event)
+		}
+		cancel()
+	}
+
+	events := make([]nostr.Event, 0, filter.Limit)
+	for evt := range sys.Store.QueryEvents(filter, DB_MAX_LIMIT) {
+		if banned, _ := isEventBanned(evt.ID); banned {
+			continue
+		}
+		if banned, _ := isPubkeyBanned(evt.PubKey); banned {
+			continue
+		}
+		events = append(events, evt)
+	}
+	slices.SortFunc(events, func(a, b nostr.Event) int { return cmp.Compare(b.CreatedAt, a.CreatedAt) })
+	return events
+}
+
+// gitStatuses returns the latest status for each of the given patches or issues, only considering
+// statuses published by the author of the target or by the repository maintainers
+func gitStatuses(ctx context.Context, relays []string, targets []nostr.Event, maintainers []ContactInfo) map[nostr.ID]string {
+	if len(targets) == 0 {
+		return map[nostr.ID]string{}
+	}
+
+	ids := make([]string, len(targets))
+	for i, target := range targets {
+		ids[i] = target.ID.Hex()
+	}
+
+	return pickGitStatuses(targets, gitQuery(ctx, relays, nostr.Filter{
+		Kinds: gitStatusKinds,
+		Tags:  nostr.TagMap{"e": ids},
+	}, "gitstatus"), maintainers)
+}
+
+// pickGitStatuses takes the latest valid status event for each target, targets without one are "Open"
+func pickGitStatuses(targets []nostr.Event, statusEvents []nostr.Event, maintainers []ContactInfo) map[nostr.ID]string {
+	statuses := make(map[nostr.ID]string, len(targets))
+	latest := make(map[nostr.ID]nostr.Timestamp, len(targets))
+
+	byId := make(map[string]*nostr.Event, len(targets))
+	for i, target := range targets {
+		byId[target.ID.Hex()] = &targets[i]
+	}
+
+	for _, status := range statusEvents {
+		for tag := range status.Tags.FindAll("e") {
+			target, ok := byId[tag[1]]
+			if !ok {
+				continue
+			}
+			if at, ok := latest[target.ID]; ok && at >= status.CreatedAt {
+				continue
+			}
+			if status.PubKey != target.PubKey && !slices.ContainsFunc(maintainers, func(c ContactInfo) bool { return c.PubKey == status.PubKey }) {
+				continue
+			}
+			latest[target.ID] = status.CreatedAt
+			statuses[target.ID] = gitStatusName(status.Kind, target.Kind)
+		}
+	}
+
+	for _, target := range targets {
+		if _, ok := statuses[target.ID]; !ok {
+			statuses[target.ID] = "Open"
+		}
+	}
+
+	return statuses
+}
+
+func gitItemSubject(event *nostr.Event) string {
+	if event.Kind == 1617 {
+		return patchSubject(event.Content)
+	}
+	if tag := event.Tags.Find("subject"); tag != nil {
+		return tag[1]
+	}
+	return strings.SplitN(event.Content, "\n", 2)[0]
+}
+
+// gitRepositoryActivity lists the most recent issues and patches for a repository
+func gitRepositoryActivity(ctx context.Context, event *nostr.Event, repo GitRepository, maxItems int) (issues []GitItem, patches []GitItem) {
+	relays := repo.Relays
+	if len(relays) == 0 {
+		relays = sys.FetchOutboxRelays(ctx, event.PubKey, 3)
+	}
+
+	address := fmt.Sprintf("%d:%s:%s", event.Kind, event.PubKey.Hex(), event.Tags.GetD())
+	events := gitQuery(ctx, relays, nostr.Filter{
+		Kinds: []nostr.Kind{1617, 1621},
+		Tags:  nostr.TagMap{"a": []string{address}},
+	}, "gitactivity")
+
+	// patch series have all their patches but the first tagged as replies, we only list the roots
+	events = slices.DeleteFunc(events, func(evt nostr.Event) bool {
+		return evt.Kind == 1617 && evt.Tags.Find("e") != nil && evt.Tags.FindWithValue("t", "root") == nil
+	})
+	if len(events) > maxItems*2 {
+		events = events[0 : maxItems*2]
+	}
+
+	statuses := gitStatuses(ctx, relays, events, repo.Maintainers)
+	for _, evt := range events {
+		item := GitItem{
+			Nevent:    nip19.EncodeNevent(evt.ID, nil, evt.PubKey),
+			Subject:   gitItemSubject(&evt),
+			Status:    statuses[evt.ID],
+			CreatedAt: evt.CreatedAt.Time().Format("2006-01-02"),
+		}
+		if evt.Kind == 1621 && len(issues) < maxItems {
+			issues = append(issues, item)
+		} else if evt.Kind == 1617 && len(patches) < maxItems {
+			patches = append(patches, item)
+		}
+	}
+
+	return issues, patches
+}
+
+// loadGitTarget fills the repository, status and comments of a patch or issue
+func loadGitTarget(ctx context.Context, event *nostr.Event, git *GitMetadata, eventRelays []string) {
+	git.Subject = gitItemSubject(event)
+	for tag := range event.Tags.FindAll("t") {
+		if tag[1] != "root" && tag[1] != "root-revision" {
+			git.Labels = append(git.Labels, tag[1])
+		}
+	}
+
+	relays := eventRelays
+	if tag := event.Tags.Find("a"); tag != nil && strings.HasPrefix(tag[1], "30617:") {
+		if pointer, err := nostr.EntityPointerFromTag(tag); err == nil {
+			ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+			repoEvent, _ := getEvent(ctx, nip19.EncodePointer(pointer), false)
+			cancel()
+			if repoEvent != nil {
+				git.Repository = parseGitRepository(ctx, repoEvent)
+				relays = appendUnique(slices.Clone(git.Repository.Relays), relays...)
+			}
+		}
+	}
+
+	git.Status = gitStatuses(ctx, relays, []nostr.Event{*event}, git.Repository.Maintainers)[event.ID]
+
+	id := event.ID.Hex()
+	comments := gitQuery(ctx, relays, nostr.Filter{
+		Kinds: []nostr.Kind{1, 1111},
+		Tags:  nostr.TagMap{"e": []string{id}},
+	}, "gitcomments")
+	comments = append(comments, gitQuery(ctx, relays, nostr.Filter{
+		Kinds: []nostr.Kind{1111},
+		Tags:  nostr.TagMap{"E": []string{id}},
+	}, "gitcomments")...)
+
+	// oldest first, like a conversation
+	slices.SortFunc(comments, func(a, b nostr.Event) int { return cmp.Compare(a.CreatedAt, b.CreatedAt) })
+	comments = slices.CompactFunc(comments, func(a, b nostr.Event) bool { return a.ID == b.ID })
+	if len(comments) > 50 {
+		comments = comments[0:50]
+	}
+
+	pubkeys := make([]nostr.PubKey, len(comments))
+	for i, comment := range comments {
+		pubkeys[i] = comment.PubKey
+	}
+	profiles := fetchProfiles(ctx, pubkeys)
+
+	for _, comment := range comments {
+		git.Comments = append(git.Comments, GitComment{
+			Nevent:    nip19.EncodeNevent(comment.ID, nil, comment.PubKey),
+			Author:    profiles[comment.PubKey],
+			CreatedAt: comment.CreatedAt.Time().Format("2006-01-02 15:04"),
+			Content:   template.HTML(basicFormatting(html.EscapeString(comment.Content), false, false, false)),
+		})
+	}
+}
+
+// patchSubject takes the commit subject from a git format-patch, without the [PATCH] prefix
+func patchSubject(patch string) string {
+	for _, line := range strings.Split(patch, "\n") {
+		if subject, ok := strings.CutPrefix(line, "Subject: "); ok {
+			if strings.HasPrefix(subject, "[") {
+				if end := strings.Index(subject, "]"); end != -1 {
+					subject = subject[end+1:]
+				}
+			}
+			return strings.TrimSpace(subject)
+		}
+		if line == "" {
+			// end of the email headers
+			break
+		}
+	}
+	return "patch"
+}
+
+// renderPatchDiff turns a git format-patch into HTML with the diff lines colored
+func renderPatchDiff(patch string) template.HTML {
+	var sb strings.Builder
+	sb.Grow(len(patch) + len(patch)/2)
+
+	sb.WriteString(`<pre class="overflow-x-auto rounded-md bg-neutral-100 p-3 text-sm leading-5 dark:bg-neutral-900"><code>`)
+	inDiff := false
+	var syntax *syntaxSpec
+	for _, line := range strings.Split(patch, "\n") {
+		if strings.HasPrefix(line, "diff --git") {
+			inDiff = true
+			syntax = syntaxForDiffHeader(line)
+		}
+
+		class := ""
+		switch {
+		case !inDiff:
+			class = "text-stone-500 dark:text-neutral-400"
+		case strings.HasPrefix(line, "diff --git"), strings.HasPrefix(line, "index "),
+			strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			class = "font-bold"
+		case strings.HasPrefix(line, "@@"):
+			class = "text-sky-600 dark:text-sky-400"
+		case strings.HasPrefix(line, "+"):
+			class = "bg-green-100 text-green-800 dark:bg-green-950 dark:text-green-300"
+		case strings.HasPrefix(line, "-"):
+			class = "bg-red-100 text-red-800 dark:bg-red-950 dark:text-red-300"
+		}
+
+		// the code itself is highlighted according to the file it belongs to
+		content := html.EscapeString(line)
+		if inDiff && len(line) > 0 && (line[0] == '+' || line[0] == '-' || line[0] == ' ') &&
+			!strings.HasPrefix(line, "+++") && !strings.HasPrefix(line, "---") {
+			content = html.EscapeString(line[0:1]) + highlightCode(line[1:], syntax)
+		}
+
+		if class != "" {
+			sb.WriteString(`<span class="` + class + `">`)
+			sb.WriteString(content)
+			sb.WriteString("</span>\n")
+		} else {
+			sb.WriteString(content)
+			sb.WriteString("\n")
+		}
+	}
+	sb.WriteString(`</code></pre>`)
+
+	return template.HTML(sb.String())
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPickGitStatuses(t *testing.T) {
+	author := nostr.MustPubKeyFromHex("7bdef7be22dd8e59f4600e044aa53a1cf975a9dc7d27df5833bc77db784a5805")
+	maintainer := nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+	stranger := nostr.MustPubKeyFromHex("97c70a44366a6535c145b333f973ea86dfdc2d7a99da618c40c64705ad98e322")
+
+	patchId, _ := nostr.IDFromHex("a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1a1")
+	issueId, _ := nostr.IDFromHex("b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2b2")
+	otherIssueId, _ := nostr.IDFromHex("c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3c3")
+
+	targets := []nostr.Event{
+		{ID: patchId, Kind: 1617, PubKey: author},
+		{ID: issueId, Kind: 1621, PubKey: author},
+		{ID: otherIssueId, Kind: 1621, PubKey: author},
+	}
+	statusEvents := []nostr.Event{
+		// the order of the events doesn't matter, the latest one wins
+		{Kind: 1632, PubKey: maintainer, CreatedAt: 100, Tags: nostr.Tags{{"e", patchId.Hex()}}},
+		{Kind: 1631, PubKey: maintainer, CreatedAt: 200, Tags: nostr.Tags{{"e", patchId.Hex()}}},
+		{Kind: 1631, PubKey: author, CreatedAt: 300, Tags: nostr.Tags{{"e", issueId.Hex()}}},
+		// strangers can't change the status
+		{Kind: 1632, PubKey: stranger, CreatedAt: 400, Tags: nostr.Tags{{"e", issueId.Hex()}}},
+		{Kind: 1632, PubKey: stranger, CreatedAt: 400, Tags: nostr.Tags{{"e", otherIssueId.Hex()}}},
+	}
+
+	statuses := pickGitStatuses(targets, statusEvents, []ContactInfo{{PubKey: maintainer}})
+	assert.Equal(t, "Applied", statuses[patchId])
+	assert.Equal(t, "Resolved", statuses[issueId])
+	assert.Equal(t, "Open", statuses[otherIssueId])
+}
+
+func TestPatchSubject(t *testing.T) {
+	assert.Equal(t, "fix the thing", patchSubject("From 123 Mon Sep 17 00:00:00 2001\nSubject: [PATCH 1/2] fix the thing\n\nbody"))
+	assert.Equal(t, "no prefix", patchSubject("Subject: no prefix\n"))
+	assert.Equal(t, "patch", patchSubject("From: someone\n\nSubject: in the body"))
+}
+
+func TestHighlightCode(t *testing.T) {
+	assert.Equal(t,
+		`<span class="`+syntaxKeywordClass+`">func</span> main() { x := <span class="`+syntaxStringClass+`">&#34;a \&#34;b&#34;</span> + <span class="`+syntaxNumberClass+`">42</span> <span class="`+syntaxCommentClass+`">// &lt;done&gt;</span>`,
+		highlightCode(`func main() { x := "a \"b" + 42 // <done>`, goSyntax))
+
+	// identifiers containing keywords or digits are left alone
+	assert.Equal(t, "format2 x1", highlightCode("format2 x1", goSyntax))
+
+	// unterminated strings and block comments go until the end of the line
+	assert.Equal(t, `a <span class="`+syntaxCommentClass+`">/* b</span>`, highlightCode("a /* b", jsSyntax))
+	assert.Equal(t, `<span class="`+syntaxStringClass+`">&#39;abc</span>`, highlightCode("'abc", pythonSyntax))
+
+	// unknown languages are just escaped
+	assert.Equal(t, "&lt;b&gt; if", highlightCode("<b> if", nil))
+}
+
+func TestRenderPatchDiff(t *testing.T) {
+	diff := string(renderPatchDiff("Subject: x\n\ndiff --git a/main.go b/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-return 1\n+return 2"))
+	assert.Contains(t, diff, `<span class="font-bold">+++ b/main.go</span>`)
+	assert.Contains(t, diff, `-<span class="`+syntaxKeywordClass+`">return</span> <span class="`+syntaxNumberClass+`">1</span></span>`)
+	assert.Contains(t, diff, `+<span class="`+syntaxKeywordClass+`">return</span> <span class="`+syntaxNumberClass+`">2</span></span>`)
+	assert.True(t, strings.HasSuffix(diff, "</code></pre>"))
+
+	assert.Nil(t, syntaxForDiffHeader("diff --git a/README b/README"))
+	assert.Equal(t, rustSyntax, syntaxForDiffHeader("diff --git a/src/lib.rs b/src/lib.rs"))
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip11"
+	"fiatjaf.com/nostr/nip19"
+	"fiatjaf.com/nostr/nip29"
+	"fiatjaf.com/nostr/sdk"
+)
+
+const (
+	GROUP_PAGE_SIZE   = 30
+	GROUP_MAX_MEMBERS = 60
+)
+
+type GroupMember struct {
+	Profile sdk.ProfileMetadata
+	Roles   []string
+}
+
+// groupLink is the code for the page of the group a message belongs to, which is the
+// naddr of the kind 39000 metadata signed by the group relay. when we couldn't get the relay
+// pubkey from its NIP-11 document we use the relay'id address, renderGroupAddress resolves it later
+func groupLink(address nip29.GroupAddress) string {
+	if address.ID == "" || address.Relay == "" {
+		return ""
+	}
+	if address.Self == nostr.ZeroPK {
+		return address.Code()
+	}
+	return nip19.EncodeNaddr(address.Self, 39000, address.ID, []string{address.Relay})
+}
+
+// renderGroupAddress sends relay'id group addresses to the page of the group metadata
+func renderGroupAddress(w http.ResponseWriter, r *http.Request, address nip29.GroupAddress) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*4)
+	defer cancel()
+
+	info, err := nip11.Fetch(ctx, address.Relay)
+	if err != nil || info.Self == nil {
+		w.Header().Set("Cache-Control", "public, s-maxage=600, max-age=600")
+		http.Error(w, "couldn't find the group relay", http.StatusNotFound)
+		return
+	}
+	address.Self = *info.Self
+
+	w.Header().Set("Cache-Control", "public, s-maxage=86400, max-age=86400")
+	http.Redirect(w, r, "/"+groupLink(address), http.StatusFound)
+}
+
+// fetchGroupRoles gets the admins (39001) and members (39002) lists of a group from its relay,
+// the total is the number of members in the list even if we don't load all of them
+func fetchGroupRoles(ctx context.Context, relay string, relayPubKey nostr.PubKey, groupId string) (admins []GroupMember, members []GroupMember, total int) {
+	fetchCtx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	var adminsEvent, membersEvent *nostr.Event
+	for ie := range sys.Pool.FetchMany(fetchCtx, []string{relay}, nostr.Filter{
+		Kinds:   []nostr.Kind{39001, 39002},
+		Authors: []nostr.PubKey{relayPubKey},
+		Tags:    nostr.TagMap{"d": []string{groupId}},
+	}, nostr.SubscriptionOptions{Label: "grouproles"}) {
+		evt := ie.Event
+		switch evt.Kind {
+		case 39001:
+			if adminsEvent == nil || evt.CreatedAt > adminsEvent.CreatedAt {
+				adminsEvent = &evt
+			}
+		case 39002:
+			if membersEvent == nil || evt.CreatedAt > membersEvent.CreatedAt {
+				membersEvent = &evt
+			}
+		}
+	}
+
+	if adminsEvent != nil {
+		for tag := range adminsEvent.Tags.FindAll("p") {
+			pk, err := nostr.PubKeyFromHex(tag[1])
+			if err != nil {
+				continue
+			}
+			admins = append(admins, GroupMember{
+				Profile: sdk.ProfileMetadata{PubKey: pk},
+				Roles:   tag[2:],
+			})
+		}
+	}
+
+	if membersEvent != nil {
+		for tag := range membersEvent.Tags.FindAll("p") {
+			pk, err := nostr.PubKeyFromHex(tag[1])
+			if err != nil {
+				continue
+			}
+			total++
+			if len(members) >= GROUP_MAX_MEMBERS ||
+				slices.ContainsFunc(admins, func(admin GroupMember) bool { return admin.Profile.PubKey == pk }) {
+				continue
+			}
+			members = append(members, GroupMember{Profile: sdk.ProfileMetadata{PubKey: pk}})
+		}
+	}
+
+	// load all the profiles at once, with their own time
+	profilesCtx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	pubkeys := make([]nostr.PubKey, 0, len(admins)+len(members))
+	for _, member := range slices.Concat(admins, members) {
+		pubkeys = append(pubkeys, member.Profile.PubKey)
+	}
+	profiles := fetchProfiles(profilesCtx, pubkeys)
+	for i := range admins {
+		admins[i].Profile = profiles[admins[i].Profile.PubKey]
+	}
+	for i := range members {
+		members[i].Profile = profiles[members[i].Profile.PubKey]
+	}
+
+	return admins, members, total
+}
+
+// fetchGroupMessages gets a page of chat messages (kind 9) and threads (kind 11) from the group relay.
+// these are not saved to our local store since they belong to that relay only.
+func fetchGroupMessages(ctx context.Context, relay string, groupId string, until nostr.Timestamp) []EnhancedEvent {
+	fetchCtx, cancel := context.WithTimeout(ctx, time.Second*4)
+	defer cancel()
+
+	messages := make([]EnhancedEvent, 0, GROUP_PAGE_SIZE)
+	for ie := range sys.Pool.FetchMany(fetchCtx, []string{relay}, nostr.Filter{
+		Kinds: []nostr.Kind{9, 11},
+		Tags:  nostr.TagMap{"h": []string{groupId}},
+		Until: until,
+		Limit: GROUP_PAGE_SIZE,
+	}, nostr.SubscriptionOptions{Label: "groupmessages"}) {
+		if isThreadEventHidden(&ie.Event) {
+			continue
+		}
+		ee := NewEnhancedEventWithoutMetadata(ie.Event)
+		ee.relays = []string{relay}
+		messages = append(messages, ee)
+	}
+
+	slices.SortFunc(messages, func(a, b EnhancedEvent) int { return cmp.Compare(b.CreatedAt, a.CreatedAt) })
+	if len(messages) > GROUP_PAGE_SIZE {
+		messages = messages[0:GROUP_PAGE_SIZE]
+	}
+
+	// only now load the authors, all at once, so they don't eat into the time we have for the relay
+	profilesCtx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	pubkeys := make([]nostr.PubKey, len(messages))
+	for i, message := range messages {
+		pubkeys[i] = message.PubKey
+	}
+	profiles := fetchProfiles(profilesCtx, pubkeys)
+	for i := range messages {
+		messages[i].author = profiles[messages[i].PubKey]
+	}
+
+	return messages
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+	"fiatjaf.com/nostr/nip29"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupLink(t *testing.T) {
+	relayPubKey := nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+
+	// with the relay pubkey we link straight to the group metadata
+	link := groupLink(nip29.GroupAddress{Relay: "wss://groups.example.com", ID: "abcd", Self: relayPubKey})
+	prefix, value, err := nip19.Decode(link)
+	assert.NoError(t, err)
+	assert.Equal(t, "naddr", prefix)
+	pointer := value.(nostr.EntityPointer)
+	assert.Equal(t, nostr.Kind(39000), pointer.Kind)
+	assert.Equal(t, relayPubKey, pointer.PublicKey)
+	assert.Equal(t, "abcd", pointer.Identifier)
+
+	// without it we fall back to the group address
+	address := nip29.GroupAddress{Relay: "wss://groups.example.com", ID: "abcd"}
+	link = groupLink(address)
+	assert.Equal(t, address.Code(), link)
+	parsed, err := nip29.ParseGroupAddress(link)
+	assert.NoError(t, err)
+	assert.Equal(t, "abcd", parsed.ID)
+
+	assert.Equal(t, "", groupLink(nip29.GroupAddress{Relay: "wss://groups.example.com"}))
+	assert.Equal(t, "", groupLink(nip29.GroupAddress{ID: "abcd"}))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"github.com/dgraph-io/ristretto"
+)
+
+var hashtagKinds = []nostr.Kind{1, 20, 1111, 30023}
+
+// recentHashtagFetches prevents us from going to the relays every time someone opens a hashtag page
+var recentHashtagFetches, _ = ristretto.NewCache(&ristretto.Config[string, bool]{
+	NumCounters: 1e5,
+	MaxCost:     1 << 14,
+	BufferItems: 64,
+})
+
+func normalizeHashtag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(tag, "#")))
+}
+
+func isHashtagProhibited(tag string) bool {
+	return slices.Contains(pornTags, tag)
+}
+
+// hashtagLastNotes gets the latest events tagged with the given hashtag from the fallback relays
+// and the local store, skipping banned and prohibited events. kinds must be a subset of hashtagKinds,
+// or empty for all of them. sitemaps only need the event itself so they can skip loading the metadata.
+func hashtagLastNotes(ctx context.Context, tag string, kinds []nostr.Kind, limit int, until nostr.Timestamp, withMetadata bool) []EnhancedEvent {
+	kinds = slices.DeleteFunc(slices.Clone(kinds), func(k nostr.Kind) bool { return !slices.Contains(hashtagKinds, k) })
+	if len(kinds) == 0 {
+		kinds = hashtagKinds
+	}
+
+	filter := nostr.Filter{
+		Kinds: kinds,
+		Tags:  nostr.TagMap{"t": []string{tag}},
+		Limit: limit,
+		Until: until,
+	}
+
+	fetchKey := tag + ":" + fmt.Sprint(kinds) + ":" + strconv.FormatInt(int64(until), 10)
+	if _, fetchedRecently := recentHashtagFetches.Get(fetchKey); !fetchedRecently {
+		recentHashtagFetches.SetWithTTL(fetchKey, true, 1, time.Minute*10)
+
+		relays := make([]string, 0, 3)
+		for len(relays) < 3 {
+			relays = appendUnique(relays, sys.FallbackRelays.Next())
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, time.Second*4)
+		for ie := range sys.Pool.FetchMany(ctx, relays, filter, nostr.SubscriptionOptions{Label: "hashtag"}) {
+			sys.Store.SaveEvent(ie.Event)
+		}
+		cancel()
+	}
+
+	// we'll have to skip some, so look at more
+	localFilter := filter
+	localFilter.Limit = DB_MAX_LIMIT
+
+	lastNotes := make([]EnhancedEvent, 0, limit)
+	for evt := range sys.Store.QueryEvents(localFilter, DB_MAX_LIMIT) {
+		if banned, _ := isEventBanned(evt.ID); banned {
+			continue
+		}
+		if banned, _ := isPubkeyBanned(evt.PubKey); banned {
+			continue
+		}
+		if hasProhibitedWordOrTag(&evt) {
+			continue
+		}
+
+		if withMetadata {
+			lastNotes = append(lastNotes, NewEnhancedEvent(ctx, evt))
+		} else {
+			lastNotes = append(lastNotes, NewEnhancedEventWithoutMetadata(evt))
+		}
+		if len(lastNotes) >= limit {
+			break
+		}
+	}
+
+	return lastNotes
+}
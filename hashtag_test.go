@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeHashtag(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		expected string
+	}{
+		{"nostr", "nostr"},
+		{"Nostr", "nostr"},
+		{"#Bitcoin", "bitcoin"},
+		{" zapathon ", "zapathon"},
+		{"#", ""},
+	} {
+		assert.Equal(t, tc.expected, normalizeHashtag(tc.input), tc.input)
+	}
+}
+
+func TestHashtagRedirect(t *testing.T) {
+	for _, tc := range []struct {
+		target   string
+		location string
+	}{
+		{"/t/Nostr", "/t/nostr"},
+		{"/t/Nostr.rss?kinds=30023", "/t/nostr.rss?kinds=30023"},
+		{"/t/Nostr?until=1700000000&seen=aaaaaaaaaaaaaaaa", "/t/nostr?until=1700000000&seen=aaaaaaaaaaaaaaaa"},
+		{"/t/%23", "/"},
+	} {
+		w := httptest.NewRecorder()
+		renderHashtagPage(w, httptest.NewRequest("GET", tc.target, nil))
+		assert.Equal(t, http.StatusFound, w.Code, tc.target)
+		assert.Equal(t, tc.location, w.Header().Get("Location"), tc.target)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"html/template"
+	"slices"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore/lmdb"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const HISTORY_MAX_REVISIONS = 100
+
+type Revision struct {
+	Event     nostr.Event
+	ID        string
+	Title     string
+	CreatedAt string
+}
+
+// historyKinds are the kinds we keep the older versions of: the ones with content people write
+// and edit, like articles and wiki pages. lists and app data change too often to be worth it.
+var historyKinds = []nostr.Kind{30023, 30024, 30402, 30403, 30818, 31922, 31923}
+
+// hasHistory says if we keep the older versions of events of this kind
+func hasHistory(kind nostr.Kind) bool {
+	return slices.Contains(historyKinds, kind)
+}
+
+// recordRevision keeps a copy of an addressable event in the history store, so when a newer
+// version replaces it in the main store we still have it
+func recordRevision(event nostr.Event) {
+	if !hasHistory(event.Kind) {
+		return
+	}
+	if err := historyStore.SaveEvent(event); err != nil {
+		// we already had it
+		return
+	}
+	pruneRevisions(event)
+}
+
+// pruneRevisions deletes the oldest versions of an address once it has more than we show
+func pruneRevisions(event nostr.Event) {
+	filter := nostr.Filter{
+		Kinds:   []nostr.Kind{event.Kind},
+		Authors: []nostr.PubKey{event.PubKey},
+		Tags:    nostr.TagMap{"d": []string{event.Tags.GetD()}},
+	}
+
+	excess := make([]nostr.ID, 0, 1)
+	i := 0
+	for evt := range historyStore.QueryEvents(filter, HISTORY_MAX_REVISIONS*2) {
+		i++
+		if i > HISTORY_MAX_REVISIONS {
+			excess = append(excess, evt.ID)
+		}
+	}
+	for _, id := range excess {
+		historyStore.DeleteEvent(id)
+	}
+}
+
+// historyRecordingStore is our main event store, it records every event with history saved to it
+// in the history store so the versions it replaces are kept even if nobody looked at them
+type historyRecordingStore struct {
+	*lmdb.LMDBBackend
+}
+
+func (hrs historyRecordingStore) SaveEvent(event nostr.Event) error {
+	recordRevision(event)
+	return hrs.LMDBBackend.SaveEvent(event)
+}
+
+func (hrs historyRecordingStore) ReplaceEvent(event nostr.Event) error {
+	recordRevision(event)
+	return hrs.LMDBBackend.ReplaceEvent(event)
+}
+
+// isRevisionOrphaned says if the main store doesn't have any version of this address anymore
+func isRevisionOrphaned(event nostr.Event) bool {
+	for range sys.Store.QueryEvents(nostr.Filter{
+		Kinds:   []nostr.Kind{event.Kind},
+		Authors: []nostr.PubKey{event.PubKey},
+		Tags:    nostr.TagMap{"d": []string{event.Tags.GetD()}},
+	}, 1) {
+		return false
+	}
+	return true
+}
+
+// fetchRevisions gets all the versions of an addressable event we can find, from the relays
+// and from our history store, newest first
+func fetchRevisions(ctx context.Context, pointer nostr.EntityPointer) []Revision {
+	filter := pointer.AsFilter()
+	filter.Limit = HISTORY_MAX_REVISIONS
+
+	relays := pointer.Relays
+	for _, url := range sys.FetchOutboxRelays(ctx, pointer.PublicKey, 3) {
+		relays = appendUnique(relays, url)
+	}
+	if len(relays) == 0 {
+		relays = appendUnique(relays, sys.FallbackRelays.Next(), sys.FallbackRelays.Next())
+	}
+
+	// most relays only keep the latest version, but some keep the older ones too
+	fetchCtx, cancel := context.WithTimeout(ctx, time.Second*4)
+	for ie := range sys.Pool.FetchMany(fetchCtx, relays, filter, nostr.SubscriptionOptions{Label: "history"}) {
+		recordRevision(ie.Event)
+	}
+	cancel()
+	for evt := range sys.Store.QueryEvents(filter, 1) {
+		recordRevision(evt)
+	}
+
+	revisions := make([]Revision, 0, 10)
+	for evt := range historyStore.QueryEvents(filter, HISTORY_MAX_REVISIONS) {
+		if slices.ContainsFunc(revisions, func(r Revision) bool { return r.Event.ID == evt.ID }) {
+			continue
+		}
+
+		title := ""
+		if tag := evt.Tags.Find("title"); tag != nil {
+			title = tag[1]
+		}
+		revisions = append(revisions, Revision{
+			Event:     evt,
+			ID:        evt.ID.Hex(),
+			Title:     title,
+			CreatedAt: evt.CreatedAt.Time().UTC().Format("2006-01-02 15:04:05 MST"),
+		})
+	}
+
+	slices.SortFunc(revisions, func(a, b Revision) int { return cmp.Compare(b.Event.CreatedAt, a.Event.CreatedAt) })
+	return revisions
+}
+
+// revisionDiff renders a line diff between the sources (markdown, djot or whatever) of two versions
+func revisionDiff(from Revision, to Revision) template.HTML {
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(revisionSource(from.Event)),
+		B:        difflib.SplitLines(revisionSource(to.Event)),
+		FromFile: from.ID[0:8] + " (" + from.CreatedAt + ")",
+		ToFile:   to.ID[0:8] + " (" + to.CreatedAt + ")",
+		Context:  3,
+	})
+	if diff == "" {
+		return ""
+	}
+
+	return renderPatchDiff("diff --git a/" + from.ID + " b/" + to.ID + "\n" + strings.TrimSuffix(diff, "\n"))
+}
+
+// revisionSource is the content plus the title and summary, since those change too
+func revisionSource(event nostr.Event) string {
+	var sb strings.Builder
+	for _, name := range []string{"title", "summary"} {
+		if tag := event.Tags.Find(name); tag != nil {
+			sb.WriteString(name + ": " + tag[1] + "\n")
+		}
+	}
+	if sb.Len() > 0 {
+		sb.WriteString("\n")
+	}
+	sb.WriteString(event.Content)
+	if !strings.HasSuffix(event.Content, "\n") {
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasHistory(t *testing.T) {
+	for _, tc := range []struct {
+		kind     nostr.Kind
+		expected bool
+	}{
+		{1, false},
+		{0, false},
+		{10002, false},
+		{30023, true},
+		{30818, true},
+		{30024, true},
+		{30402, true},
+		{31922, true},
+		{30000, false},
+		{30078, false},
+		{39000, false},
+		{39089, false},
+		{40000, false},
+	} {
+		assert.Equal(t, tc.expected, hasHistory(tc.kind), "kind %d", tc.kind)
+	}
+}
+
+func TestRevisionSource(t *testing.T) {
+	assert.Equal(t, "title: Hello\nsummary: a greeting\n\nhi there\n", revisionSource(nostr.Event{
+		Kind:    30023,
+		Tags:    nostr.Tags{{"d", "hello"}, {"summary", "a greeting"}, {"title", "Hello"}},
+		Content: "hi there",
+	}))
+	assert.Equal(t, "just content\n", revisionSource(nostr.Event{Kind: 30023, Content: "just content\n"}))
+}
+
+func TestRevisionDiff(t *testing.T) {
+	from := Revision{
+		Event:     nostr.Event{Kind: 30023, Content: "one\ntwo\nthree"},
+		ID:        "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		CreatedAt: "2024-01-01 00:00:00 UTC",
+	}
+	to := Revision{
+		Event:     nostr.Event{Kind: 30023, Content: "one\n2\nthree"},
+		ID:        "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+		CreatedAt: "2024-02-01 00:00:00 UTC",
+	}
+
+	diff := string(revisionDiff(from, to))
+	assert.Contains(t, diff, `<span class="bg-red-100 text-red-800 dark:bg-red-950 dark:text-red-300">-two</span>`)
+	assert.Contains(t, diff, `<span class="bg-green-100 text-green-800 dark:bg-green-950 dark:text-green-300">+2</span>`)
+
+	assert.Empty(t, revisionDiff(from, from))
+}
+
+func TestRecordRevisionPrunes(t *testing.T) {
+	setupTestStores(t)
+	pk := nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+
+	// lists don't get a history
+	saveTestEvent(t, nostr.Event{Kind: 30000, PubKey: pk, CreatedAt: 1700000000, Tags: nostr.Tags{{"d", "friends"}}})
+	for range historyStore.QueryEvents(nostr.Filter{Kinds: []nostr.Kind{30000}}, 10) {
+		t.Fatal("recorded a revision of a list")
+	}
+
+	// articles do, up to HISTORY_MAX_REVISIONS of them
+	for i := range HISTORY_MAX_REVISIONS + 5 {
+		evt := nostr.Event{
+			Kind:      30023,
+			PubKey:    pk,
+			CreatedAt: nostr.Timestamp(1700000000 + i),
+			Tags:      nostr.Tags{{"d", "hello"}},
+			Content:   "version " + strconv.Itoa(i),
+		}
+		evt.ID = evt.GetID()
+		recordRevision(evt)
+	}
+
+	revisions := slices.Collect(historyStore.QueryEvents(nostr.Filter{Kinds: []nostr.Kind{30023}}, HISTORY_MAX_REVISIONS*2))
+	assert.Len(t, revisions, HISTORY_MAX_REVISIONS)
+	for _, evt := range revisions {
+		assert.GreaterOrEqual(t, evt.CreatedAt, nostr.Timestamp(1700000005), "the oldest ones should be gone")
+	}
+}
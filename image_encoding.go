@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nfnt/resize"
+)
+
+// WEBP_ENCODING_TIMEOUT is how long we let ffmpeg take for each webp attempt before giving up on it
+const WEBP_ENCODING_TIMEOUT = time.Second * 3
+
+type ImageFormat string
+
+const (
+	FormatPNG  ImageFormat = "png"
+	FormatJPEG ImageFormat = "jpeg"
+	FormatWebP ImageFormat = "webp"
+)
+
+func (f ImageFormat) ContentType() string {
+	return "image/" + string(f)
+}
+
+// getImageFormat picks the format from the extension at the end of the url or, when there isn't one,
+// from what the client says it accepts
+func getImageFormat(ext string, accept string) ImageFormat {
+	switch ext {
+	case ".jpg", ".jpeg":
+		return FormatJPEG
+	case ".webp":
+		return FormatWebP
+	case ".png":
+		return FormatPNG
+	}
+
+	if strings.Contains(accept, "image/webp") {
+		return FormatWebP
+	}
+	return FormatPNG
+}
+
+// getImageSize gives the dimensions of the preview images for each platform:
+// 2:1 for twitter, square for the messengers that crop to a square and the
+// standard opengraph 1.91:1 everywhere else
+func getImageSize(style Style) (width int, height int) {
+	switch style {
+	case StyleTwitter:
+		return 700, 350
+	case StyleWhatsapp, StyleTelegram:
+		return 700, 700
+	default:
+		return 700, 366
+	}
+}
+
+// getImageMaxBytes is the size after which crawlers start to ignore our images
+func getImageMaxBytes(style Style) int {
+	if style == StyleWhatsapp {
+		return 300 * 1024
+	}
+	return 1024 * 1024
+}
+
+// encodeImage encodes the image in the given format, lowering the quality and then the dimensions
+// until it fits in maxBytes. webp falls back to png if it can't be encoded.
+func encodeImage(ctx context.Context, img image.Image, format ImageFormat, maxBytes int) ([]byte, ImageFormat, error) {
+	var buf bytes.Buffer
+	for range 4 {
+		for _, quality := range []int{90, 75, 60} {
+			buf.Reset()
+			if err := encodeImageAs(ctx, &buf, img, format, quality); err != nil {
+				if format == FormatWebP {
+					log.Warn().Err(err).Msg("failed to encode webp, falling back to png")
+					format = FormatPNG
+					continue
+				}
+				return nil, format, err
+			}
+
+			if buf.Len() <= maxBytes {
+				return buf.Bytes(), format, nil
+			}
+			if format == FormatPNG {
+				// there is no quality to lower
+				break
+			}
+		}
+
+		// still too big, make it smaller
+		bounds := img.Bounds()
+		img = resize.Resize(uint(bounds.Dx()*3/4), 0, img, resize.Lanczos3)
+	}
+
+	// we did what we could
+	return buf.Bytes(), format, nil
+}
+
+func encodeImageAs(ctx context.Context, w io.Writer, img image.Image, format ImageFormat, quality int) error {
+	switch format {
+	case FormatJPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case FormatWebP:
+		// there is no webp encoder in the standard library so we use ffmpeg, like we do for videos
+		var input bytes.Buffer
+		if err := png.Encode(&input, img); err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(ctx, WEBP_ENCODING_TIMEOUT)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "ffmpeg", "-f", "png_pipe", "-i", "-",
+			"-c:v", "libwebp", "-quality", strconv.Itoa(quality), "-f", "webp", "-")
+		cmd.Stdin = &input
+		cmd.Stdout = w
+		cmd.Stderr = nil
+		return cmd.Run()
+	default:
+		return png.Encode(w, img)
+	}
+}
+
+// writeImage encodes one of our generated images and writes it, the other headers must be set before
+func writeImage(ctx context.Context, w http.ResponseWriter, img image.Image, format ImageFormat, style Style) {
+	data, format, err := encodeImage(ctx, img, format, getImageMaxBytes(style))
+	if err != nil {
+		log.Warn().Err(err).Msg("error encoding image")
+		http.Error(w, "error encoding image!", 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Write(data)
+}
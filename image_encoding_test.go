@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetImageFormat(t *testing.T) {
+	for _, tc := range []struct {
+		ext      string
+		accept   string
+		expected ImageFormat
+	}{
+		{".png", "image/webp,*/*", FormatPNG},
+		{".jpg", "", FormatJPEG},
+		{".jpeg", "image/webp", FormatJPEG},
+		{".webp", "", FormatWebP},
+		{"", "image/avif,image/webp,image/apng,*/*;q=0.8", FormatWebP},
+		{"", "image/png,image/*;q=0.8", FormatPNG},
+		{"", "", FormatPNG},
+	} {
+		assert.Equal(t, tc.expected, getImageFormat(tc.ext, tc.accept), tc.ext+" "+tc.accept)
+	}
+}
+
+// noise is an image that doesn't compress well
+func noise(width, height int) image.Image {
+	random := rand.New(rand.NewSource(42))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{uint8(random.Intn(256)), uint8(random.Intn(256)), uint8(random.Intn(256)), 255})
+		}
+	}
+	return img
+}
+
+func TestEncodeImageMaxBytes(t *testing.T) {
+	img := noise(400, 300)
+
+	data, format, err := encodeImage(context.Background(), img, FormatJPEG, 1<<20)
+	assert.NoError(t, err)
+	assert.Equal(t, FormatJPEG, format)
+	decoded, err := jpeg.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, img.Bounds(), decoded.Bounds())
+
+	// too big: first the quality goes down, then the image gets smaller
+	data, format, err = encodeImage(context.Background(), img, FormatJPEG, 30*1024)
+	assert.NoError(t, err)
+	assert.Equal(t, FormatJPEG, format)
+	assert.LessOrEqual(t, len(data), 30*1024)
+	decoded, err = jpeg.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Less(t, decoded.Bounds().Dx(), img.Bounds().Dx())
+
+	// when nothing is small enough we still get the smallest we could make
+	data, format, err = encodeImage(context.Background(), img, FormatPNG, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, FormatPNG, format)
+	assert.NotEmpty(t, data)
+}
+
+func TestEncodeImageWebPFallback(t *testing.T) {
+	// ffmpeg can't even start with a canceled context, so we get a png
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	data, format, err := encodeImage(ctx, noise(50, 50), FormatWebP, 1<<20)
+	assert.NoError(t, err)
+	assert.Equal(t, FormatPNG, format)
+	assert.Equal(t, []byte("\x89PNG"), data[0:4])
+}
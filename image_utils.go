@@ -324,6 +324,24 @@ func cropToSquare(img image.Image) image.Image {
 	return squareImg
 }
 
+// cropToFill crops the center of the image to the aspect ratio of width x height and then
+// resizes it to that size, like cropToSquare but for any shape
+func cropToFill(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	cropWidth, cropHeight := bounds.Dx(), bounds.Dy()
+	if cropWidth*height > cropHeight*width {
+		cropWidth = cropHeight * width / height
+	} else {
+		cropHeight = cropWidth * height / width
+	}
+	cropped := image.NewRGBA(image.Rect(0, 0, cropWidth, cropHeight))
+	draw.Draw(cropped, cropped.Bounds(), img, image.Point{
+		X: bounds.Min.X + (bounds.Dx()-cropWidth)/2,
+		Y: bounds.Min.Y + (bounds.Dy()-cropHeight)/2,
+	}, draw.Src)
+	return resize.Resize(uint(width), uint(height), cropped, resize.Lanczos3)
+}
+
 func lookupScript(r rune) int {
 	// binary search
 	for i, j := 0, len(scriptRanges); i < j; {
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// threeStripes is a width x height image split in three vertical stripes: red, green and blue
+func threeStripes(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		c := color.RGBA{0, 255, 0, 255}
+		if x < width/3 {
+			c = color.RGBA{255, 0, 0, 255}
+		} else if x >= width*2/3 {
+			c = color.RGBA{0, 0, 255, 255}
+		}
+		for y := 0; y < height; y++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// isMostly checks that a pixel is mostly of one of the pure colors the stripes have
+func isMostly(img image.Image, x, y int, expected color.RGBA) bool {
+	r, g, b, _ := img.At(x, y).RGBA()
+	er, eg, eb, _ := expected.RGBA()
+	near := func(a, b uint32) bool { return max(a, b)-min(a, b) < 0x1000 }
+	return near(r, er) && near(g, eg) && near(b, eb)
+}
+
+func TestCropToFill(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	green := color.RGBA{0, 255, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+
+	// wide images lose their sides
+	cropped := cropToFill(threeStripes(600, 100), 90, 90)
+	assert.Equal(t, image.Rect(0, 0, 90, 90), cropped.Bounds())
+	for _, x := range []int{0, 45, 89} {
+		assert.True(t, isMostly(cropped, x, 45, green), "x=%d", x)
+	}
+
+	// tall images keep all their width
+	cropped = cropToFill(threeStripes(300, 900), 120, 60)
+	assert.Equal(t, image.Rect(0, 0, 120, 60), cropped.Bounds())
+	assert.True(t, isMostly(cropped, 2, 30, red))
+	assert.True(t, isMostly(cropped, 60, 30, green))
+	assert.True(t, isMostly(cropped, 117, 30, blue))
+
+	// images that don't start at 0,0 are cropped around their own center
+	sub := threeStripes(900, 100).SubImage(image.Rect(200, 0, 500, 100))
+	cropped = cropToFill(sub, 50, 50)
+	assert.Equal(t, image.Rect(0, 0, 50, 50), cropped.Bounds())
+	for _, x := range []int{0, 25, 49} {
+		assert.True(t, isMostly(cropped, x, 25, green), "x=%d", x)
+	}
+}
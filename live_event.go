@@ -0,0 +1,124 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"html"
+	"html/template"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+	"fiatjaf.com/nostr/sdk"
+)
+
+const LIVE_CHAT_MAX_MESSAGES = 50
+
+type LiveParticipant struct {
+	Profile sdk.ProfileMetadata
+	Role    string
+}
+
+type LiveChatMessage struct {
+	Author    sdk.ProfileMetadata
+	Nevent    string
+	Content   template.HTML
+	CreatedAt nostr.Timestamp
+}
+
+// sentAt is when the message was sent, the date is only shown for the old ones
+func (message LiveChatMessage) sentAt() string {
+	sent := message.CreatedAt.Time().UTC()
+	if time.Since(sent) < time.Hour*24 {
+		return sent.Format("15:04 MST")
+	}
+	return sent.Format("02 Jan 15:04 MST")
+}
+
+// loadLiveEventExtras reads the streaming details and participants of a kind 30311 event
+// and fetches the latest kind 1311 chat messages sent to it
+func loadLiveEventExtras(ctx context.Context, event *nostr.Event, le *Kind30311Metadata, relays []string) {
+	if tag := event.Tags.Find("streaming"); tag != nil {
+		le.StreamURL = tag[1]
+	}
+	if tag := event.Tags.Find("recording"); tag != nil {
+		le.RecordingURL = tag[1]
+	}
+	if tag := event.Tags.Find("starts"); tag != nil {
+		if ts, err := strconv.ParseInt(tag[1], 10, 64); err == nil {
+			le.StartsAt = time.Unix(ts, 0).UTC().Format("02 Jan 2006 15:04 MST")
+		}
+	}
+
+	for tag := range event.Tags.FindAll("p") {
+		pk, err := nostr.PubKeyFromHex(tag[1])
+		if err != nil || slices.ContainsFunc(le.People, func(p LiveParticipant) bool { return p.Profile.PubKey == pk }) {
+			continue
+		}
+		participant := LiveParticipant{Profile: sdk.ProfileMetadata{PubKey: pk}}
+		if len(tag) >= 4 {
+			participant.Role = tag[3]
+		}
+		le.People = append(le.People, participant)
+	}
+
+	// chat messages
+	for tag := range event.Tags.FindAll("relays") {
+		for _, url := range tag[1:] {
+			relays = appendUnique(relays, nostr.NormalizeURL(url))
+		}
+	}
+	for len(relays) < 3 {
+		relays = appendUnique(relays, sys.FallbackRelays.Next())
+	}
+
+	filter := nostr.Filter{
+		Kinds: []nostr.Kind{1311},
+		Tags:  nostr.TagMap{"a": []string{strconv.Itoa(int(event.Kind)) + ":" + event.PubKey.Hex() + ":" + event.Tags.GetD()}},
+		Limit: LIVE_CHAT_MAX_MESSAGES,
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, time.Second*3)
+	for ie := range sys.Pool.FetchMany(fetchCtx, relays, filter, nostr.SubscriptionOptions{Label: "livechat"}) {
+		sys.Store.SaveEvent(ie.Event)
+	}
+	cancel()
+
+	for evt := range sys.Store.QueryEvents(filter, LIVE_CHAT_MAX_MESSAGES) {
+		if isThreadEventHidden(&evt) {
+			continue
+		}
+		le.Chat = append(le.Chat, LiveChatMessage{
+			Author:    sdk.ProfileMetadata{PubKey: evt.PubKey},
+			Nevent:    nip19.EncodeNevent(evt.ID, nil, evt.PubKey),
+			Content:   template.HTML(basicFormatting(html.EscapeString(evt.Content), false, false, false)),
+			CreatedAt: evt.CreatedAt,
+		})
+	}
+
+	// oldest first, like in a chat
+	slices.SortFunc(le.Chat, func(a, b LiveChatMessage) int { return cmp.Compare(a.CreatedAt, b.CreatedAt) })
+
+	// now load the profiles of the participants and of everybody in the chat at once
+	pubkeys := make([]nostr.PubKey, 0, len(le.People)+len(le.Chat))
+	for _, person := range le.People {
+		pubkeys = append(pubkeys, person.Profile.PubKey)
+	}
+	for _, message := range le.Chat {
+		pubkeys = append(pubkeys, message.Author.PubKey)
+	}
+	profiles := fetchProfiles(ctx, pubkeys)
+	for i := range le.People {
+		le.People[i].Profile = profiles[le.People[i].Profile.PubKey]
+	}
+	for i := range le.Chat {
+		le.Chat[i].Author = profiles[le.Chat[i].Author.PubKey]
+	}
+}
+
+func isHLS(url string) bool {
+	return strings.Contains(strings.ToLower(url), ".m3u8")
+}
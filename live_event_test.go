@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsHLS(t *testing.T) {
+	for _, tc := range []struct {
+		url      string
+		expected bool
+	}{
+		{"https://stream.example.com/live/abc/index.m3u8", true},
+		{"https://stream.example.com/live/abc/INDEX.M3U8?token=1", true},
+		{"https://stream.example.com/recording.mp4", false},
+		{"", false},
+	} {
+		assert.Equal(t, tc.expected, isHLS(tc.url), tc.url)
+	}
+}
+
+func TestLiveChatMessageSentAt(t *testing.T) {
+	recent := time.Now().Add(-time.Minute).UTC()
+	assert.Equal(t, recent.Format("15:04 MST"), LiveChatMessage{CreatedAt: nostr.Timestamp(recent.Unix())}.sentAt())
+	assert.Equal(t, "14 Nov 22:13 UTC", LiveChatMessage{CreatedAt: 1700000000}.sentAt())
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"github.com/dgraph-io/ristretto"
+)
+
+type lnurlPayResponse struct {
+	AllowsNostr bool   `json:"allowsNostr"`
+	NostrPubkey string `json:"nostrPubkey"`
+}
+
+// zapperCache maps each pubkey to the pubkey that signs the zap receipts for them, which is
+// the zero pubkey when they can't receive zaps
+var zapperCache, _ = ristretto.NewCache(&ristretto.Config[nostr.PubKey, nostr.PubKey]{
+	NumCounters: 1e5,
+	MaxCost:     1 << 14,
+	BufferItems: 64,
+})
+
+// lnurlpURL is where the lnurl-pay parameters of a lightning address are, or "" if it isn't one
+func lnurlpURL(lud16 string) string {
+	name, domain, ok := strings.Cut(strings.TrimSpace(lud16), "@")
+	if !ok || name == "" || domain == "" || strings.ContainsAny(name+domain, "/@?# ") {
+		return ""
+	}
+	return "https://" + strings.ToLower(domain) + "/.well-known/lnurlp/" + name
+}
+
+// fetchZapper finds the pubkey NIP-57 zap receipts for this pubkey must be signed by, from the
+// lightning address in their profile. ok is false when they can't receive zaps.
+func fetchZapper(ctx context.Context, pubkey nostr.PubKey) (zapper nostr.PubKey, ok bool) {
+	if cached, found := zapperCache.Get(pubkey); found {
+		return cached, cached != nostr.ZeroPK
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*4)
+	defer cancel()
+
+	zapper = nostr.ZeroPK
+	defer func() {
+		if zapper == nostr.ZeroPK {
+			// this could have been just a slow server, so try again sooner
+			zapperCache.SetWithTTL(pubkey, zapper, 1, time.Minute*30)
+		} else {
+			zapperCache.SetWithTTL(pubkey, zapper, 1, time.Hour*6)
+		}
+	}()
+
+	profile := sys.FetchProfileMetadata(ctx, pubkey)
+	url := lnurlpURL(profile.LUD16)
+	if url == "" {
+		return zapper, false
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return zapper, false
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return zapper, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return zapper, false
+	}
+
+	var params lnurlPayResponse
+	if err := json.NewDecoder(resp.Body).Decode(&params); err != nil || !params.AllowsNostr {
+		return zapper, false
+	}
+	pk, err := nostr.PubKeyFromHex(params.NostrPubkey)
+	if err != nil {
+		return zapper, false
+	}
+
+	zapper = pk
+	return zapper, true
+}
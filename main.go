@@ -25,6 +25,7 @@ type Settings struct {
 	InternalDBPath      string `envconfig:"DISK_CACHE_PATH" default:"/tmp/njump-internal"`
 	EventStorePath      string `envconfig:"EVENT_STORE_PATH" default:"/tmp/njump-db"`
 	KVStorePath         string `envconfig:"KV_STORE_PATH" default:"/tmp/njump-kv"`
+	HistoryStorePath    string `envconfig:"HISTORY_STORE_PATH" default:"/tmp/njump-history"`
 	HintsMemoryDumpPath string `envconfig:"HINTS_SAVE_PATH" default:"/tmp/njump-hints.json"`
 	TailwindDebug       bool   `envconfig:"TAILWIND_DEBUG"`
 	RelayConfigPath     string `envconfig:"RELAY_CONFIG_PATH"`
@@ -150,8 +151,10 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go updateArchives(ctx)
+	go updateEventsArchive(ctx)
 	go deleteOldCachedEvents(ctx, s.CacheRetentionDays)
 	go outboxHintsFileLoaderSaver(ctx)
+	go updateSearchIndex(ctx)
 
 	// expose our internal cache as a relay (mostly for debugging purposes)
 	relay := khatru.NewRelay()
@@ -176,13 +179,27 @@ func main() {
 	sub.HandleFunc("/proxy/", proxy)
 	sub.HandleFunc("/robots.txt", renderRobots)
 	sub.HandleFunc("/r/", renderRelayPage)
+	sub.HandleFunc("/t/", renderHashtagPage)
+	sub.HandleFunc("/search", renderSearch)
+	sub.HandleFunc("/w/{topic}", renderWikiTopic)
+	sub.HandleFunc("/opensearch.xml", renderOpenSearch)
+	sub.HandleFunc("/sitemap-index.xml", renderSitemapIndex)
+	sub.HandleFunc("/api/v1/event/{code}", renderAPIEvent)
+	sub.HandleFunc("/api/v1/profile/{code}", renderAPIProfile)
+	sub.HandleFunc("/.well-known/webfinger", renderWebFinger)
+	sub.HandleFunc("/ap/{npub}", renderActivityPubActor)
+	sub.HandleFunc("/ap/{npub}/outbox", renderActivityPubOutbox)
+	sub.HandleFunc("/ap/{npub}/inbox", renderActivityPubInbox)
+	sub.HandleFunc("/sitemap/{archive}/{page}", renderArchiveSitemap)
 	sub.HandleFunc("/random", redirectToRandom)
 	sub.HandleFunc("/e/", redirectFromESlash)
 	sub.HandleFunc("/p/", redirectFromPSlash)
 	sub.HandleFunc("/favicon.ico", redirectToFavicon)
 	sub.HandleFunc("/embed/{code}", renderEmbedjs)
+	sub.HandleFunc("/embed/t/{tag}", renderEmbedjs)
 	sub.HandleFunc("/about", renderAbout)
 	sub.HandleFunc("/{code}", renderEvent)
+	sub.HandleFunc("/", renderSubPath)
 	sub.HandleFunc("/{$}", renderHomepage)
 
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
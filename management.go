@@ -11,6 +11,7 @@ import (
 
 func deleteEvent(id nostr.ID) {
 	sys.Store.DeleteEvent(id)
+	historyStore.DeleteEvent(id)
 	sys.EraseAccessTime(id)
 	sys.EraseEventRelays(id)
 }
@@ -19,6 +20,9 @@ func deleteAllEventsFromPubKey(pk nostr.PubKey) {
 	for evt := range sys.Store.QueryEvents(nostr.Filter{Authors: []nostr.PubKey{pk}}, DB_MAX_LIMIT) {
 		deleteEvent(evt.ID)
 	}
+	for evt := range historyStore.QueryEvents(nostr.Filter{Authors: []nostr.PubKey{pk}}, 999999) {
+		historyStore.DeleteEvent(evt.ID)
+	}
 }
 
 func setupRelayManagement(relay *khatru.Relay) {
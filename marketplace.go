@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+// NIP-15 stalls and products have all their data as JSON in the content
+type nip15Stall struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Currency    string `json:"currency"`
+	Shipping    []struct {
+		ID      string   `json:"id"`
+		Name    string   `json:"name"`
+		Cost    float64  `json:"cost"`
+		Regions []string `json:"regions"`
+	} `json:"shipping"`
+}
+
+type nip15Product struct {
+	ID          string     `json:"id"`
+	StallID     string     `json:"stall_id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Images      []string   `json:"images"`
+	Currency    string     `json:"currency"`
+	Price       float64    `json:"price"`
+	Quantity    *int       `json:"quantity"`
+	Specs       [][]string `json:"specs"`
+	Shipping    []struct {
+		ID   string  `json:"id"`
+		Cost float64 `json:"cost"`
+	} `json:"shipping"`
+}
+
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', -1, 64)
+}
+
+func parseNip15Stall(event *nostr.Event) ListingMetadata {
+	listing := ListingMetadata{
+		Naddr: nip19.EncodeNaddr(event.PubKey, event.Kind, event.Tags.GetD(), nil),
+	}
+
+	var stall nip15Stall
+	if err := json.Unmarshal([]byte(event.Content), &stall); err != nil {
+		return listing
+	}
+
+	listing.Title = stall.Name
+	listing.Summary = stall.Description
+	listing.Currency = stall.Currency
+	for _, zone := range stall.Shipping {
+		listing.Shipping = append(listing.Shipping, ShippingZone{
+			ID:      zone.ID,
+			Name:    zone.Name,
+			Cost:    formatPrice(zone.Cost),
+			Regions: zone.Regions,
+		})
+	}
+
+	return listing
+}
+
+func parseNip15Product(event *nostr.Event) (ListingMetadata, string) {
+	listing := ListingMetadata{
+		Naddr: nip19.EncodeNaddr(event.PubKey, event.Kind, event.Tags.GetD(), nil),
+	}
+
+	var product nip15Product
+	if err := json.Unmarshal([]byte(event.Content), &product); err != nil {
+		return listing, ""
+	}
+
+	listing.Title = product.Name
+	listing.Summary = product.Description
+	listing.Images = product.Images
+	listing.Price = formatPrice(product.Price)
+	listing.Currency = product.Currency
+	listing.Quantity = product.Quantity
+	listing.Specs = product.Specs
+	for _, zone := range product.Shipping {
+		listing.Shipping = append(listing.Shipping, ShippingZone{
+			ID:   zone.ID,
+			Cost: formatPrice(zone.Cost),
+		})
+	}
+	for tag := range event.Tags.FindAll("t") {
+		listing.Hashtags = append(listing.Hashtags, tag[1])
+	}
+
+	return listing, product.StallID
+}
+
+func parseNip99Listing(event *nostr.Event) ListingMetadata {
+	listing := ListingMetadata{
+		Naddr: nip19.EncodeNaddr(event.PubKey, event.Kind, event.Tags.GetD(), nil),
+	}
+
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+
+		switch tag[0] {
+		case "title":
+			listing.Title = tag[1]
+		case "summary":
+			listing.Summary = tag[1]
+		case "location":
+			listing.Location = tag[1]
+		case "status":
+			listing.Status = tag[1]
+		case "image":
+			listing.Images = append(listing.Images, tag[1])
+		case "t":
+			listing.Hashtags = append(listing.Hashtags, tag[1])
+		case "price":
+			listing.Price = tag[1]
+			if len(tag) >= 3 {
+				listing.Currency = tag[2]
+			}
+			if len(tag) >= 4 {
+				listing.Frequency = tag[3]
+			}
+		}
+	}
+
+	return listing
+}
+
+// attachStallToProduct fetches the stall a NIP-15 product belongs to so we can link to it
+// and so we can name the shipping zones, which are only referenced by id in the product
+func attachStallToProduct(ctx context.Context, listing *ListingMetadata, author nostr.PubKey, stallID string, relays []string) {
+	if stallID == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	stallCode := nip19.EncodeNaddr(author, 30017, stallID, relays)
+	listing.Stall = stallCode
+
+	stallEvent, _ := getEvent(ctx, stallCode, false)
+	if stallEvent == nil {
+		return
+	}
+
+	stall := parseNip15Stall(stallEvent)
+	listing.StallName = stall.Title
+	for i, zone := range listing.Shipping {
+		for _, stallZone := range stall.Shipping {
+			if stallZone.ID == zone.ID {
+				listing.Shipping[i].Name = stallZone.Name
+				listing.Shipping[i].Regions = stallZone.Regions
+
+				// the product cost is an extra on top of the base stall cost
+				base, _ := strconv.ParseFloat(stallZone.Cost, 64)
+				extra, _ := strconv.ParseFloat(zone.Cost, 64)
+				listing.Shipping[i].Cost = formatPrice(base + extra)
+				break
+			}
+		}
+	}
+}
+
+// stallProducts lists the products we have stored locally for a given NIP-15 stall
+func stallProducts(stall *nostr.Event, maxProducts int) []ListingMetadata {
+	products := make([]ListingMetadata, 0, maxProducts)
+	for evt := range sys.Store.QueryEvents(nostr.Filter{
+		Kinds:   []nostr.Kind{30018},
+		Authors: []nostr.PubKey{stall.PubKey},
+	}, DB_MAX_LIMIT) {
+		product, stallID := parseNip15Product(&evt)
+		if stallID != stall.Tags.GetD() {
+			continue
+		}
+		products = append(products, product)
+		if len(products) >= maxProducts {
+			break
+		}
+	}
+	return products
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatPrice(t *testing.T) {
+	assert.Equal(t, "10", formatPrice(10))
+	assert.Equal(t, "10.5", formatPrice(10.5))
+	assert.Equal(t, "0.00021", formatPrice(0.00021))
+}
+
+func TestParseNip15Product(t *testing.T) {
+	pubkey := nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+
+	listing, stallID := parseNip15Product(&nostr.Event{
+		Kind:   30018,
+		PubKey: pubkey,
+		Tags:   nostr.Tags{{"d", "p1"}, {"t", "tshirts"}},
+		Content: `{"id":"p1","stall_id":"s1","name":"T-shirt","description":"a nice one",` +
+			`"images":["https://example.com/t.png"],"currency":"sat","price":2100.5,"quantity":3,` +
+			`"specs":[["size","M"]],"shipping":[{"id":"z1","cost":100}]}`,
+	})
+	assert.Equal(t, "s1", stallID)
+	assert.Equal(t, "T-shirt", listing.Title)
+	assert.Equal(t, "a nice one", listing.Summary)
+	assert.Equal(t, "2100.5", listing.Price)
+	assert.Equal(t, "sat", listing.Currency)
+	assert.Equal(t, []string{"https://example.com/t.png"}, listing.Images)
+	assert.Equal(t, [][]string{{"size", "M"}}, listing.Specs)
+	assert.Equal(t, []string{"tshirts"}, listing.Hashtags)
+	if assert.NotNil(t, listing.Quantity) {
+		assert.Equal(t, 3, *listing.Quantity)
+	}
+	if assert.Len(t, listing.Shipping, 1) {
+		assert.Equal(t, "z1", listing.Shipping[0].ID)
+		assert.Equal(t, "100", listing.Shipping[0].Cost)
+	}
+
+	// invalid content still gives us a link to the product
+	listing, stallID = parseNip15Product(&nostr.Event{Kind: 30018, PubKey: pubkey, Content: "not json"})
+	assert.Equal(t, "", stallID)
+	assert.Equal(t, "", listing.Title)
+	assert.Contains(t, listing.Naddr, "naddr1")
+}
+
+func TestParseNip99Listing(t *testing.T) {
+	listing := parseNip99Listing(&nostr.Event{
+		Kind:   30402,
+		PubKey: nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"),
+		Tags: nostr.Tags{
+			{"d", "apartment"},
+			{"title", "Apartment in Lisbon"},
+			{"summary", "two bedrooms"},
+			{"location", "Lisbon"},
+			{"status", "active"},
+			{"image", "https://example.com/a.jpg"},
+			{"image", "https://example.com/b.jpg"},
+			{"price", "1500", "EUR", "month"},
+			{"t", "realestate"},
+			{"price"},
+		},
+	})
+	assert.Equal(t, "Apartment in Lisbon", listing.Title)
+	assert.Equal(t, "two bedrooms", listing.Summary)
+	assert.Equal(t, "Lisbon", listing.Location)
+	assert.Equal(t, "active", listing.Status)
+	assert.Equal(t, []string{"https://example.com/a.jpg", "https://example.com/b.jpg"}, listing.Images)
+	assert.Equal(t, "1500", listing.Price)
+	assert.Equal(t, "EUR", listing.Currency)
+	assert.Equal(t, "month", listing.Frequency)
+	assert.Equal(t, []string{"realestate"}, listing.Hashtags)
+}
@@ -26,6 +26,7 @@ type RelayConfig struct {
 	Everything      []string `json:"everything"`
 	Profiles        []string `json:"profiles"`
 	JustIds         []string `json:"justIds"`
+	Search          []string `json:"search"`
 }
 
 const DB_MAX_LIMIT = 500
@@ -34,6 +35,9 @@ var (
 	sys    *sdk.System
 	serial int
 
+	// historyStore keeps the superseded versions of addressable events
+	historyStore *lmdb.LMDBBackend
+
 	relayConfig = RelayConfig{
 		Everything: nil, // use the defaults from nostr-sdk
 		Profiles:   nil, // use the defaults from nostr-sdk
@@ -42,6 +46,10 @@ var (
 			"wss://relay.noswhere.com",
 			"wss://relay.damus.io",
 		},
+		Search: []string{
+			"wss://relay.nostr.band",
+			"wss://search.nos.today",
+		},
 	}
 
 	defaultTrustedPubKeys = []nostr.PubKey{
@@ -84,6 +92,14 @@ func initSystem() func() {
 		return func() {}
 	}
 
+	historyStore = &lmdb.LMDBBackend{
+		Path: s.HistoryStorePath,
+	}
+	if err := historyStore.Init(); err != nil {
+		log.Fatal().Err(err).Msg("failed to init history store")
+		return func() {}
+	}
+
 	kv, err := bolt_kv.NewStore(s.KVStorePath)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to init kvstore")
@@ -92,7 +108,7 @@ func initSystem() func() {
 
 	sys = sdk.NewSystem()
 	sys.KVStore = kv
-	sys.Store = db
+	sys.Store = historyRecordingStore{db}
 
 	sys.Pool.QueryMiddleware = sys.TrackQueryAttempts
 	sys.Pool.EventMiddleware = sys.TrackEventHintsAndRelays
@@ -103,7 +119,10 @@ func initSystem() func() {
 		},
 	}
 
-	return db.Close
+	return func() {
+		db.Close()
+		historyStore.Close()
+	}
 }
 
 func getEvent(ctx context.Context, code string, skipLocalStore bool) (*nostr.Event, error) {
@@ -204,80 +223,22 @@ func getMetadata(ctx context.Context, event nostr.Event) sdk.ProfileMetadata {
 	}
 }
 
-func authorLastNotes(ctx context.Context, pubkey nostr.PubKey) (lastNotes []EnhancedEvent, justFetched bool) {
-	limit := 100
-
-	filter := nostr.Filter{
-		Kinds:   []nostr.Kind{nostr.KindTextNote},
-		Authors: []nostr.PubKey{pubkey},
-		Limit:   limit,
-	}
-
-	lastNotes = make([]EnhancedEvent, 0, filter.Limit)
-	latestTimestamp := nostr.Timestamp(0)
-
-	// fetch from local store if available
-	next, done := iter.Pull(sys.Store.QueryEvents(filter, DB_MAX_LIMIT))
-	evt, has := next()
-	if has {
-		lastNotes = append(lastNotes, NewEnhancedEventWithoutMetadata(evt))
-		latestTimestamp = evt.CreatedAt
-		for evt, more := next(); more; evt, more = next() {
-			lastNotes = append(lastNotes, NewEnhancedEventWithoutMetadata(evt))
-		}
-	}
-	done()
-
-	if (len(lastNotes) < limit/10) ||
-		(len(lastNotes) < limit/5 && latestTimestamp > nostr.Now()-60*60*24*2) ||
-		(len(lastNotes) < limit/2 && latestTimestamp < nostr.Now()-60*60*24*2) {
-		// if we didn't get enough notes then try to fetch from external relays (but do not wait for it)
-		justFetched = true
-
-		go func() {
-			ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
-			defer cancel()
-
-			relays := sys.FetchOutboxRelays(ctx, pubkey, 3)
-			for len(relays) < 3 {
-				relays = appendUnique(relays, sys.FallbackRelays.Next())
-			}
-
-			ch := sys.Pool.FetchMany(ctx, relays, filter, nostr.SubscriptionOptions{Label: "authorlast"})
-		out:
-			for {
-				select {
-				case ie, more := <-ch:
-					if !more {
-						break out
-					}
-
-					ee := NewEnhancedEventWithoutMetadata(ie.Event)
-					ee.relays = appendUnique([]string{ie.Relay.URL}, sys.GetEventRelays(ie.Event.ID)...)
-					lastNotes = append(lastNotes, ee)
-
-					sys.Store.SaveEvent(ie.Event)
+// relayLastNotes yields notes seen on a relay from the local store and then from the relay itself
+// if we don't have enough. until can be given to get older notes, for pagination, and kinds to
+// get something other than notes.
+func relayLastNotes(ctx context.Context, hostname string, kinds []nostr.Kind, limit int, until nostr.Timestamp) iter.Seq[nostr.Event] {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*4)
 
-					// track this only the first time this event is downloaded for the profile page so we keep these fresh
-					sys.TrackEventAccessTime(ie.Event.ID)
-				case <-ctx.Done():
-					break out
-				}
-			}
-		}()
+	localKinds, relayKinds := []nostr.Kind{1, 1111}, []nostr.Kind{1}
+	if len(kinds) > 0 {
+		localKinds, relayKinds = kinds, kinds
 	}
 
-	return lastNotes, justFetched
-}
-
-func relayLastNotes(ctx context.Context, hostname string, limit int) iter.Seq[nostr.Event] {
-	ctx, cancel := context.WithTimeout(ctx, time.Second*4)
-
 	url := nostr.NormalizeURL(hostname)
 	return func(yield func(nostr.Event) bool) {
 		defer cancel()
 
-		for evt := range sys.Store.QueryEvents(nostr.Filter{Kinds: []nostr.Kind{1, 1111}}, 99999) {
+		for evt := range sys.Store.QueryEvents(nostr.Filter{Kinds: localKinds, Until: until}, 99999) {
 			if slices.Contains(sys.GetEventRelays(evt.ID), url) {
 				limit--
 
@@ -297,8 +258,9 @@ func relayLastNotes(ctx context.Context, hostname string, limit int) iter.Seq[no
 
 			if relay, err := sys.Pool.EnsureRelay(hostname); err == nil {
 				for evt := range relay.QueryEvents(nostr.Filter{
-					Kinds: []nostr.Kind{1},
+					Kinds: relayKinds,
 					Limit: limit,
+					Until: until,
 				}) {
 					sys.Store.SaveEvent(evt)
 
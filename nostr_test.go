@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/eventstore/lmdb"
+	"fiatjaf.com/nostr/sdk"
+)
+
+// setupTestStores gives the test a system with empty local stores, like initSystem does, so the
+// functions that read from sys.Store and historyStore can be tested without any relays
+func setupTestStores(t *testing.T) {
+	t.Helper()
+
+	db := &lmdb.LMDBBackend{Path: t.TempDir()}
+	if err := db.Init(); err != nil {
+		t.Fatal(err)
+	}
+	history := &lmdb.LMDBBackend{Path: t.TempDir()}
+	if err := history.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	previousSys, previousHistoryStore := sys, historyStore
+	sys = sdk.NewSystem()
+	sys.Store = historyRecordingStore{db}
+	historyStore = history
+
+	t.Cleanup(func() {
+		db.Close()
+		history.Close()
+		sys, historyStore = previousSys, previousHistoryStore
+	})
+}
+
+// saveTestEvent fills in the id of the event and stores it
+func saveTestEvent(t *testing.T, evt nostr.Event) nostr.Event {
+	t.Helper()
+	evt.ID = evt.GetID()
+	if err := sys.Store.SaveEvent(evt); err != nil {
+		t.Fatal(err)
+	}
+	return evt
+}
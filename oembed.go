@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"html"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
+
+	"fiatjaf.com/nostr/nip11"
+	"fiatjaf.com/nostr/sdk"
 )
 
 type OEmbedResponse struct {
@@ -36,6 +43,52 @@ type OEmbedResponse struct {
 	HTML string `json:"html,omitempty" xml:"html,omitempty"`
 }
 
+// oembedURL is the discovery URL for a page on this host, callers append the &format=
+func oembedURL(host string, path string) string {
+	return (&url.URL{
+		Scheme: "https",
+		Host:   host,
+		Path:   "/services/oembed",
+		RawQuery: (url.Values{
+			"url": {"https://" + host + path},
+		}).Encode(),
+	}).String()
+}
+
+// addOEmbedLinkHeaders advertises the oembed endpoints in the headers, the same links
+// also go in the page head through HeadParams.Oembed
+func addOEmbedLinkHeaders(w http.ResponseWriter, oembed string) {
+	w.Header().Add("Link", "<"+oembed+"&format=json>; rel=\"alternate\"; type=\"application/json+oembed\"")
+	w.Header().Add("Link", "<"+oembed+"&format=xml>; rel=\"alternate\"; type=\"text/xml+oembed\"")
+}
+
+func requestHost(r *http.Request) string {
+	host := r.Header.Get("X-Forwarded-Host")
+	if host == "" {
+		host = r.Host
+	}
+	return host
+}
+
+// oembedIframe is the html for rich embeds, it points to the ?embed=yes view of the page and
+// respects the consumer's maxwidth and maxheight
+func oembedIframe(r *http.Request, res *OEmbedResponse, src string, width int, height int) {
+	if maxWidth, err := strconv.Atoi(r.URL.Query().Get("maxwidth")); err == nil && maxWidth > 0 && maxWidth < width {
+		width = maxWidth
+	}
+	if maxHeight, err := strconv.Atoi(r.URL.Query().Get("maxheight")); err == nil && maxHeight > 0 && maxHeight < height {
+		height = maxHeight
+	}
+
+	res.Type = "rich"
+	res.Width = width
+	res.Height = height
+	res.HTML = fmt.Sprintf(
+		`<iframe src="%s" width="%d" height="%d" frameborder="0" style="border:0;max-width:100%%;" loading="lazy" allowfullscreen></iframe>`,
+		html.EscapeString(src), width, height,
+	)
+}
+
 func renderOEmbed(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -48,52 +101,121 @@ func renderOEmbed(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid url: "+msg, 400)
 		return
 	}
-	code := strings.Split(targetURL.Path, "/")[1]
-
-	if !strings.HasPrefix(code, "nevent1") {
-		http.Error(w, "oembed is only supported for nevent1 codes, not '"+code+"'", 400)
-		return
-	}
-
-	host := r.Header.Get("X-Forwarded-Host")
-
-	data, err := grabData(ctx, code)
-	if err != nil {
-		w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
-		log.Warn().Err(err).Str("code", code).Msg("event error on oembed")
-		http.Error(w, "error fetching event: "+err.Error(), http.StatusNotFound)
-		return
-	} else if data.event.Event == nil {
-		w.Header().Set("Cache-Control", "public, s-maxage=1200, max-age=1200")
-		log.Warn().Err(err).Str("code", code).Msg("event not found on oembed")
-		http.Error(w, "no event found", http.StatusNotFound)
-		return
-	}
+	path := strings.TrimPrefix(targetURL.Path, "/")
 
+	host := requestHost(r)
 	res := OEmbedResponse{
 		Version:      "1.0",
 		ProviderName: "njump",
 		ProviderURL:  "https://" + host,
-		Title:        data.event.author.Name + " wrote",
-		AuthorName:   data.event.authorLong(),
-		AuthorURL:    fmt.Sprintf("https://%s/%s", host, data.event.Npub()),
 	}
 
 	switch {
-	case data.video != "":
-		res.Type = "video"
-		res.HTML = fmt.Sprintf(`<video controls><source src="%s"></video>`, data.video)
-	case data.image != "":
-		res.Type = "image"
-		res.URL = data.image
-		res.HTML = fmt.Sprintf(`<img src="%s">`, data.image)
+	case strings.HasPrefix(path, "r/"):
+		hostname := strings.TrimPrefix(path, "r/")
+		if len(hostname) < 3 || strings.Contains(hostname, "/") {
+			http.Error(w, "invalid relay url", 400)
+			return
+		}
+
+		info, _ := nip11.Fetch(ctx, hostname)
+		res.Title = hostname + " - Nostr Relay"
+		if info.Name != "" {
+			res.Title = info.Name + " - Nostr Relay"
+		}
+		res.ThumbnailURL = info.Icon
+		oembedIframe(r, &res, fmt.Sprintf("https://%s/r/%s?embed=yes", host, hostname), 550, 420)
+		w.Header().Set("Cache-Control", "public, s-maxage=86400, max-age=86400")
+
+	case strings.HasPrefix(path, "npub1") || strings.HasPrefix(path, "nprofile1"):
+		code := strings.Split(path, "/")[0]
+		pp := sdk.InputToProfile(ctx, code)
+		if pp == nil {
+			http.Error(w, "invalid profile code", 400)
+			return
+		}
+		if banned, _ := isPubkeyBanned(pp.PublicKey); banned {
+			w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
+			http.Error(w, "pubkey banned", http.StatusNotFound)
+			return
+		}
+
+		profileCtx, cancel := context.WithTimeout(ctx, time.Second*5)
+		profile := sys.FetchProfileMetadata(profileCtx, pp.PublicKey)
+		cancel()
+		if isMaliciousBridged(profile) {
+			w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
+			http.Error(w, "profile is malicious", http.StatusNotFound)
+			return
+		}
+
+		res.Title = profile.ShortName() + " on Nostr"
+		res.AuthorName = profile.ShortName()
+		res.AuthorURL = fmt.Sprintf("https://%s/%s", host, profile.Npub())
+		res.ThumbnailURL = profile.Picture
+		oembedIframe(r, &res, fmt.Sprintf("https://%s/%s?embed=yes", host, code), 550, 320)
+		w.Header().Set("Cache-Control", "public, s-maxage=1800, max-age=1800")
+
+	case strings.HasPrefix(path, "nevent1") || strings.HasPrefix(path, "note1") || strings.HasPrefix(path, "naddr1"):
+		code := strings.Split(path, "/")[0]
+		data, err := grabData(ctx, code)
+		if err != nil {
+			w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
+			log.Warn().Err(err).Str("code", code).Msg("event error on oembed")
+			http.Error(w, "error fetching event: "+err.Error(), http.StatusNotFound)
+			return
+		} else if data.event.Event == nil {
+			w.Header().Set("Cache-Control", "public, s-maxage=1200, max-age=1200")
+			log.Warn().Err(err).Str("code", code).Msg("event not found on oembed")
+			http.Error(w, "no event found", http.StatusNotFound)
+			return
+		}
+
+		res.Title = data.event.author.Name + " wrote"
+		if data.event.subject != "" {
+			res.Title = data.event.subject
+		}
+		res.AuthorName = data.event.authorLong()
+		res.AuthorURL = fmt.Sprintf("https://%s/%s", host, data.event.Npub())
+		res.ThumbnailURL = data.cover
+
+		src := fmt.Sprintf("https://%s/%s?embed=yes", host, code)
+		switch {
+		case data.templateId == LiveEvent:
+			if data.kind30311Metadata != nil && data.kind30311Metadata.Title != "" {
+				res.Title = data.kind30311Metadata.Title
+			}
+			res.ThumbnailURL = data.image
+			oembedIframe(r, &res, src, 640, 560)
+		case data.templateId == LongForm || data.templateId == WikiEvent:
+			oembedIframe(r, &res, src, 640, 720)
+		case data.video != "":
+			res.Type = "video"
+			res.HTML = fmt.Sprintf(`<video controls><source src="%s"></video>`, data.video)
+		case data.image != "":
+			res.Type = "image"
+			res.URL = data.image
+			res.HTML = fmt.Sprintf(`<img src="%s">`, data.image)
+		default:
+			oembedIframe(r, &res, src, 550, 400)
+		}
+
+		engagement := fetchEngagement(ctx, data.event.Event)
+		if !engagement.isEmpty() && res.Type != "rich" {
+			res.HTML += fmt.Sprintf(`<p>%s</p>`, html.EscapeString(engagement.summary()))
+		}
+		if !engagement.isEmpty() || data.templateId == LiveEvent {
+			res.CacheAge = 300
+			w.Header().Set("Cache-Control", "public, s-maxage=300, max-age=300")
+		} else {
+			w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
+		}
+
 	default:
-		res.Type = "rich"
-		res.HTML = data.content
+		http.Error(w, "oembed is not supported for '"+path+"'", 404)
+		return
 	}
 
-	w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
-
 	format := r.URL.Query().Get("format")
 	if format == "xml" {
 		w.Header().Add("Content-Type", "text/xml")
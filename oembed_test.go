@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOEmbedURL(t *testing.T) {
+	oembed := oembedURL("njump.test", "/"+testNpub)
+	assert.Equal(t, "https://njump.test/services/oembed?url=https%3A%2F%2Fnjump.test%2F"+testNpub, oembed)
+
+	w := httptest.NewRecorder()
+	addOEmbedLinkHeaders(w, oembed)
+	assert.Equal(t, []string{
+		"<" + oembed + "&format=json>; rel=\"alternate\"; type=\"application/json+oembed\"",
+		"<" + oembed + "&format=xml>; rel=\"alternate\"; type=\"text/xml+oembed\"",
+	}, w.Header().Values("Link"))
+}
+
+func TestRequestHost(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://127.0.0.1:2999/", nil)
+	assert.Equal(t, "127.0.0.1:2999", requestHost(r))
+
+	r.Header.Set("X-Forwarded-Host", "njump.test")
+	assert.Equal(t, "njump.test", requestHost(r))
+}
+
+func TestOEmbedIframe(t *testing.T) {
+	for _, tc := range []struct {
+		query  string
+		width  int
+		height int
+	}{
+		{"", 550, 400},
+		{"?maxwidth=300", 300, 400},
+		{"?maxwidth=300&maxheight=200", 300, 200},
+		{"?maxwidth=900&maxheight=900", 550, 400},
+		{"?maxwidth=0&maxheight=nope", 550, 400},
+	} {
+		res := OEmbedResponse{}
+		oembedIframe(httptest.NewRequest("GET", "/services/oembed"+tc.query, nil), &res, "https://njump.test/x?embed=yes&a=b", 550, 400)
+		assert.Equal(t, "rich", res.Type, tc.query)
+		assert.Equal(t, tc.width, res.Width, tc.query)
+		assert.Equal(t, tc.height, res.Height, tc.query)
+		assert.Contains(t, res.HTML, `src="https://njump.test/x?embed=yes&amp;a=b"`, tc.query)
+	}
+}
+
+func TestRenderOEmbedInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		target string
+		status int
+	}{
+		{"/services/oembed", http.StatusBadRequest},
+		{"/services/oembed?url=https%3A%2F%2Fnjump.test%2Fr%2Fa%2Fb", http.StatusBadRequest},
+		{"/services/oembed?url=https%3A%2F%2Fnjump.test%2Fabout", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		renderOEmbed(w, httptest.NewRequest("GET", tc.target, nil))
+		assert.Equal(t, tc.status, w.Code, tc.target)
+	}
+}
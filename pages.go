@@ -28,6 +28,12 @@ const (
 	StarterPack
 	Highlight
 	GroupMetadata
+	Zap
+	Badge
+	Listing
+	Poll
+	Git
+	CalendarCollection
 	Other
 )
 
@@ -46,6 +52,10 @@ type OpenGraphParams struct {
 
 	// this is the main text we should always have
 	Text string
+
+	// for marketplace listings, so previews can show the price
+	PriceAmount   string
+	PriceCurrency string
 }
 
 type DetailsParams struct {
@@ -59,6 +69,7 @@ type DetailsParams struct {
 	Kind            int
 	KindNIP         string
 	KindDescription string
+	HistoryURL      string
 	Extra           templ.Component
 }
 
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"iter"
+	"strconv"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+func parsePoll(event *nostr.Event) PollMetadata {
+	poll := PollMetadata{
+		PollType: "singlechoice",
+	}
+
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+
+		switch tag[0] {
+		case "option":
+			if len(tag) >= 3 {
+				poll.Options = append(poll.Options, PollOption{ID: tag[1], Label: tag[2]})
+			}
+		case "relay":
+			poll.Relays = appendUnique(poll.Relays, nostr.NormalizeURL(tag[1]))
+		case "polltype":
+			poll.PollType = tag[1]
+		case "endsAt":
+			if ts, err := strconv.ParseInt(tag[1], 10, 64); err == nil {
+				poll.EndsAt = nostr.Timestamp(ts)
+			}
+		}
+	}
+
+	return poll
+}
+
+// tallyPoll counts the kind 1018 responses to a poll, taking only the latest response from each pubkey.
+// responses are fetched from the relays the poll specifies and merged with the ones we have stored locally.
+func tallyPoll(ctx context.Context, event *nostr.Event, poll *PollMetadata) {
+	filter := nostr.Filter{
+		Kinds: []nostr.Kind{1018},
+		Tags:  nostr.TagMap{"e": []string{event.ID.Hex()}},
+	}
+	if poll.EndsAt != 0 {
+		filter.Until = poll.EndsAt
+	}
+
+	if len(poll.Relays) > 0 {
+		ctx, cancel := context.WithTimeout(ctx, time.Second*4)
+		for ie := range sys.Pool.FetchMany(ctx, poll.Relays, filter, nostr.SubscriptionOptions{Label: "pollresponses"}) {
+			sys.Store.SaveEvent(ie.Event)
+		}
+		cancel()
+	}
+
+	tallyPollResponses(poll, sys.Store.QueryEvents(filter, DB_MAX_LIMIT*4))
+}
+
+// tallyPollResponses counts the votes from the given responses into the poll options
+func tallyPollResponses(poll *PollMetadata, responses iter.Seq[nostr.Event]) {
+	latest := make(map[nostr.PubKey]nostr.Event)
+	for response := range responses {
+		if poll.EndsAt != 0 && response.CreatedAt > poll.EndsAt {
+			continue
+		}
+		if previous, ok := latest[response.PubKey]; ok && previous.CreatedAt >= response.CreatedAt {
+			continue
+		}
+		latest[response.PubKey] = response
+	}
+
+	for _, response := range latest {
+		counted := make([]string, 0, 1)
+		for tag := range response.Tags.FindAll("response") {
+			if poll.PollType != "multiplechoice" && len(counted) > 0 {
+				break
+			}
+			counted = appendUnique(counted, tag[1])
+		}
+		if len(counted) == 0 {
+			continue
+		}
+
+		poll.TotalVoters++
+		for _, optionId := range counted {
+			for i, option := range poll.Options {
+				if option.ID == optionId {
+					poll.Options[i].Votes++
+					break
+				}
+			}
+		}
+	}
+}
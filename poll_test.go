@@ -0,0 +1,73 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePoll(t *testing.T) {
+	poll := parsePoll(&nostr.Event{
+		Kind: 1068,
+		Tags: nostr.Tags{
+			{"option", "a", "Yes"},
+			{"option", "b", "No"},
+			{"option", "c"},
+			{"relay", "wss://relay.example.com"},
+			{"relay", "wss://relay.example.com"},
+			{"polltype", "multiplechoice"},
+			{"endsAt", "1700000000"},
+		},
+	})
+	assert.Equal(t, []PollOption{{ID: "a", Label: "Yes"}, {ID: "b", Label: "No"}}, poll.Options)
+	assert.Len(t, poll.Relays, 1)
+	assert.Equal(t, "multiplechoice", poll.PollType)
+	assert.Equal(t, nostr.Timestamp(1700000000), poll.EndsAt)
+
+	poll = parsePoll(&nostr.Event{Kind: 1068, Tags: nostr.Tags{{"endsAt", "soon"}}})
+	assert.Equal(t, "singlechoice", poll.PollType)
+	assert.Equal(t, nostr.Timestamp(0), poll.EndsAt)
+}
+
+func TestTallyPollResponses(t *testing.T) {
+	alice := nostr.MustPubKeyFromHex("7bdef7be22dd8e59f4600e044aa53a1cf975a9dc7d27df5833bc77db784a5805")
+	bob := nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+	carol := nostr.MustPubKeyFromHex("97c70a44366a6535c145b333f973ea86dfdc2d7a99da618c40c64705ad98e322")
+
+	responses := []nostr.Event{
+		// alice changed her mind, only the latest response counts
+		{PubKey: alice, CreatedAt: 100, Tags: nostr.Tags{{"response", "a"}}},
+		{PubKey: alice, CreatedAt: 200, Tags: nostr.Tags{{"response", "b"}}},
+		// bob picked two options
+		{PubKey: bob, CreatedAt: 150, Tags: nostr.Tags{{"response", "a"}, {"response", "b"}, {"response", "a"}}},
+		// carol voted after the poll ended
+		{PubKey: carol, CreatedAt: 1000, Tags: nostr.Tags{{"response", "a"}}},
+	}
+
+	for _, tc := range []struct {
+		pollType string
+		a, b     int
+	}{
+		{"singlechoice", 1, 1},
+		{"multiplechoice", 1, 2},
+	} {
+		poll := PollMetadata{
+			Options:  []PollOption{{ID: "a", Label: "Yes"}, {ID: "b", Label: "No"}},
+			PollType: tc.pollType,
+			EndsAt:   500,
+		}
+		tallyPollResponses(&poll, slices.Values(responses))
+		assert.Equal(t, 2, poll.TotalVoters, tc.pollType)
+		assert.Equal(t, tc.a, poll.Options[0].Votes, tc.pollType)
+		assert.Equal(t, tc.b, poll.Options[1].Votes, tc.pollType)
+	}
+}
+
+func TestPollPercent(t *testing.T) {
+	poll := PollMetadata{TotalVoters: 3}
+	assert.Equal(t, 33, poll.percent(PollOption{Votes: 1}))
+	assert.Equal(t, 100, poll.percent(PollOption{Votes: 3}))
+	assert.Equal(t, 0, PollMetadata{}.percent(PollOption{Votes: 1}))
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"iter"
+	"slices"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"github.com/dgraph-io/ristretto"
+)
+
+type ProfileTab struct {
+	ID    string
+	Title string
+	Kinds []nostr.Kind
+	Limit int
+
+	// accept filters out events that match the kinds but don't belong in this tab
+	accept func(ee EnhancedEvent) bool
+
+	// shouldFetch decides if we must go to the author's relays given what we have locally
+	shouldFetch func(count int, limit int, latest nostr.Timestamp) bool
+}
+
+// Path is the suffix we append to the profile URL to get to this tab
+func (tab ProfileTab) Path() string {
+	if tab.ID == "notes" {
+		return ""
+	}
+	return "/" + tab.ID
+}
+
+var profileTabs = []ProfileTab{
+	{
+		ID:     "notes",
+		Title:  "Notes",
+		Kinds:  []nostr.Kind{nostr.KindTextNote},
+		Limit:  100,
+		accept: func(ee EnhancedEvent) bool { return !ee.isReply() },
+		shouldFetch: func(count int, limit int, latest nostr.Timestamp) bool {
+			return (count < limit/10) ||
+				(count < limit/5 && latest > nostr.Now()-60*60*24*2) ||
+				(count < limit/2 && latest < nostr.Now()-60*60*24*2)
+		},
+	},
+	{
+		ID:          "articles",
+		Title:       "Articles",
+		Kinds:       []nostr.Kind{30023},
+		Limit:       50,
+		shouldFetch: fetchWhenBelow(10),
+	},
+	{
+		ID:          "media",
+		Title:       "Media",
+		Kinds:       []nostr.Kind{20, 21, 22},
+		Limit:       60,
+		shouldFetch: fetchWhenBelow(12),
+	},
+	{
+		ID:          "replies",
+		Title:       "Replies",
+		Kinds:       []nostr.Kind{nostr.KindTextNote},
+		Limit:       100,
+		accept:      func(ee EnhancedEvent) bool { return ee.isReply() },
+		shouldFetch: fetchWhenBelow(20),
+	},
+	{
+		ID:          "highlights",
+		Title:       "Highlights",
+		Kinds:       []nostr.Kind{9802},
+		Limit:       50,
+		shouldFetch: fetchWhenBelow(10),
+	},
+}
+
+func fetchWhenBelow(minimum int) func(int, int, nostr.Timestamp) bool {
+	return func(count int, limit int, latest nostr.Timestamp) bool { return count < minimum }
+}
+
+func getProfileTab(id string) (ProfileTab, bool) {
+	if id == "" {
+		return profileTabs[0], true
+	}
+	for _, tab := range profileTabs {
+		if tab.ID == id {
+			return tab, true
+		}
+	}
+	return ProfileTab{}, false
+}
+
+// getProfileTabByKinds finds the first tab that shows exactly the given kinds, so kind 1 means "notes", not "replies"
+func getProfileTabByKinds(kinds []nostr.Kind) (ProfileTab, bool) {
+	for _, tab := range profileTabs {
+		if slices.Equal(tab.Kinds, kinds) {
+			return tab, true
+		}
+	}
+	return ProfileTab{}, false
+}
+
+// recentTabFetches remembers which tabs we've fetched from relays recently so people with
+// just a few articles or highlights don't cause us to hit their relays on every page view
+var recentTabFetches, _ = ristretto.NewCache(&ristretto.Config[string, bool]{
+	NumCounters: 1e6,
+	MaxCost:     1 << 16,
+	BufferItems: 64,
+})
+
+// authorLastEvents gets the latest events from an author for a profile tab from the local store,
+// and if there aren't enough of them fetches more from the author's relays in the background.
+// when until is given we're paginating back in time, in that case we wait for the relays.
+func authorLastEvents(ctx context.Context, pubkey nostr.PubKey, tab ProfileTab, until nostr.Timestamp) (lastEvents []EnhancedEvent, justFetched bool) {
+	filter := nostr.Filter{
+		Kinds:   tab.Kinds,
+		Authors: []nostr.PubKey{pubkey},
+		Limit:   tab.Limit,
+		Until:   until,
+	}
+
+	queryLocal := func() ([]EnhancedEvent, nostr.Timestamp) {
+		lastEvents := make([]EnhancedEvent, 0, filter.Limit)
+		latestTimestamp := nostr.Timestamp(0)
+
+		localFilter := filter
+		if tab.accept != nil {
+			// we'll have to skip some, so look at more
+			localFilter.Limit = DB_MAX_LIMIT
+		}
+		next, done := iter.Pull(sys.Store.QueryEvents(localFilter, DB_MAX_LIMIT))
+		defer done()
+		for evt, more := next(); more && len(lastEvents) < tab.Limit; evt, more = next() {
+			ee := NewEnhancedEventWithoutMetadata(evt)
+			if tab.accept != nil && !tab.accept(ee) {
+				continue
+			}
+			if latestTimestamp == 0 {
+				latestTimestamp = evt.CreatedAt
+			}
+			lastEvents = append(lastEvents, ee)
+		}
+		return lastEvents, latestTimestamp
+	}
+
+	// fetch from local store if available
+	lastEvents, latestTimestamp := queryLocal()
+
+	if until != 0 {
+		// older pages: if we don't have a full page locally go to the relays and wait for them
+		if len(lastEvents) < tab.Limit {
+			fetchAuthorEvents(ctx, pubkey, filter, time.Second*4)
+			lastEvents, _ = queryLocal()
+		}
+		return lastEvents, false
+	}
+
+	fetchKey := pubkey.Hex() + ":" + tab.ID
+	if _, fetchedRecently := recentTabFetches.Get(fetchKey); fetchedRecently && tab.ID != "notes" {
+		return lastEvents, false
+	}
+
+	if tab.shouldFetch(len(lastEvents), tab.Limit, latestTimestamp) {
+		// if we didn't get enough events then try to fetch from external relays (but do not wait for it)
+		justFetched = true
+		recentTabFetches.SetWithTTL(fetchKey, true, 1, time.Hour)
+
+		go fetchAuthorEvents(context.Background(), pubkey, filter, time.Second*15)
+	}
+
+	return lastEvents, justFetched
+}
+
+// fetchAuthorEvents gets events from the author's outbox relays into our local store
+func fetchAuthorEvents(ctx context.Context, pubkey nostr.PubKey, filter nostr.Filter, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	relays := sys.FetchOutboxRelays(ctx, pubkey, 3)
+	for len(relays) < 3 {
+		relays = appendUnique(relays, sys.FallbackRelays.Next())
+	}
+
+	for ie := range sys.Pool.FetchMany(ctx, relays, filter, nostr.SubscriptionOptions{Label: "authorlast"}) {
+		sys.Store.SaveEvent(ie.Event)
+
+		// track this only the first time this event is downloaded for the profile page so we keep these fresh
+		sys.TrackEventAccessTime(ie.Event.ID)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProfileTab(t *testing.T) {
+	for _, tc := range []struct {
+		id       string
+		expected string
+		path     string
+		ok       bool
+	}{
+		{"", "notes", "", true},
+		{"notes", "notes", "", true},
+		{"articles", "articles", "/articles", true},
+		{"replies", "replies", "/replies", true},
+		{"nothing", "", "", false},
+	} {
+		tab, ok := getProfileTab(tc.id)
+		assert.Equal(t, tc.ok, ok, tc.id)
+		assert.Equal(t, tc.expected, tab.ID, tc.id)
+		if ok {
+			assert.Equal(t, tc.path, tab.Path(), tc.id)
+		}
+	}
+}
+
+func TestGetProfileTabByKinds(t *testing.T) {
+	tab, ok := getProfileTabByKinds([]nostr.Kind{1})
+	assert.True(t, ok)
+	assert.Equal(t, "notes", tab.ID)
+
+	tab, ok = getProfileTabByKinds([]nostr.Kind{30023})
+	assert.True(t, ok)
+	assert.Equal(t, "articles", tab.ID)
+
+	_, ok = getProfileTabByKinds([]nostr.Kind{1, 30023})
+	assert.False(t, ok)
+}
+
+func TestProfileTabAcceptsReplies(t *testing.T) {
+	note := NewEnhancedEventWithoutMetadata(nostr.Event{Kind: 1, Content: "gm"})
+	reply := NewEnhancedEventWithoutMetadata(nostr.Event{
+		Kind:    1,
+		Content: "gm to you too",
+		Tags: nostr.Tags{
+			{"e", "8ba5fe0e6e4a3e9b7f1c2a6d7e3b9c4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d", "", "reply"},
+		},
+	})
+
+	notes, _ := getProfileTab("notes")
+	replies, _ := getProfileTab("replies")
+	assert.True(t, notes.accept(note))
+	assert.False(t, notes.accept(reply))
+	assert.False(t, replies.accept(note))
+	assert.True(t, replies.accept(reply))
+}
@@ -40,7 +40,7 @@ func redirectToRandom(w http.ResponseWriter, r *http.Request) {
 
 	// otherwise try to pick an event
 	const RELAY = "wss://nostr.wine"
-	for evt := range relayLastNotes(ctx, RELAY, 1) {
+	for evt := range relayLastNotes(ctx, RELAY, nil, 1, 0) {
 		target = "/" + nip19.EncodeNevent(evt.ID, []string{RELAY}, evt.PubKey)
 		return
 	}
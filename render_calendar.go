@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"fiatjaf.com/nostr/nip19"
+)
+
+// renderCalendarICS serves NIP-52 calendar events and calendars as iCalendar files
+func renderCalendarICS(w http.ResponseWriter, r *http.Request, code string) {
+	ctx := r.Context()
+
+	if prefix, _, err := nip19.Decode(code); err != nil || prefix != "naddr" {
+		w.Header().Set("Cache-Control", "public, immutable, s-maxage=86400, max-age=86400")
+		http.Error(w, "only naddr codes can be exported as .ics", http.StatusNotFound)
+		return
+	}
+
+	event, err := getEvent(ctx, code, false)
+	if err != nil || event == nil {
+		w.Header().Set("Cache-Control", "public, s-maxage=1200, max-age=1200")
+		http.Error(w, "no event found", http.StatusNotFound)
+		return
+	}
+
+	var ics string
+	var filename string
+	switch event.Kind {
+	case 31922, 31923:
+		entry := parseCalendarEntry(*event)
+		ics = calendarICS("", []CalendarEntry{entry})
+		filename = entry.Title
+		w.Header().Set("Cache-Control", "public, s-maxage=3600, max-age=3600")
+	case 31924:
+		title := event.Tags.GetD()
+		if titleTag := event.Tags.Find("title"); titleTag != nil {
+			title = titleTag[1]
+		}
+		upcoming, past := fetchCalendarEntries(ctx, event)
+		ics = calendarICS(title, append(upcoming, past...))
+		filename = title
+		// calendar apps will poll this so it shouldn't be cached for long
+		w.Header().Set("Cache-Control", "public, s-maxage=900, max-age=900")
+	default:
+		w.Header().Set("Cache-Control", "public, immutable, s-maxage=86400, max-age=86400")
+		http.Error(w, "not a calendar event", http.StatusNotFound)
+		return
+	}
+
+	filename = strings.Map(func(r rune) rune {
+		if r == '"' || r == '/' || r == '\\' || r < 32 {
+			return -1
+		}
+		return r
+	}, filename)
+	if filename == "" {
+		filename = "event"
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="`+filename+`.ics"`)
+	w.Write([]byte(ics))
+}
@@ -2,6 +2,12 @@ package main
 
 import (
 	"net/http"
+	"strconv"
+)
+
+const (
+	EMBED_DEFAULT_LIMIT = 5
+	EMBED_MAX_LIMIT     = 20
 )
 
 func renderEmbedjs(w http.ResponseWriter, r *http.Request) {
@@ -9,3 +15,12 @@ func renderEmbedjs(w http.ResponseWriter, r *http.Request) {
 	fileContent, _ := static.ReadFile("static/embed.js")
 	w.Write(fileContent)
 }
+
+// embedLimit is how many items the timeline widgets should show
+func embedLimit(r *http.Request) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		return EMBED_DEFAULT_LIMIT
+	}
+	return min(limit, EMBED_MAX_LIMIT)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbedLimit(t *testing.T) {
+	for _, tc := range []struct {
+		query    string
+		expected int
+	}{
+		{"", EMBED_DEFAULT_LIMIT},
+		{"&limit=3", 3},
+		{"&limit=1", 1},
+		{"&limit=0", EMBED_DEFAULT_LIMIT},
+		{"&limit=-4", EMBED_DEFAULT_LIMIT},
+		{"&limit=many", EMBED_DEFAULT_LIMIT},
+		{"&limit=500", EMBED_MAX_LIMIT},
+	} {
+		assert.Equal(t, tc.expected, embedLimit(httptest.NewRequest("GET", "/npub1xyz?embed=yes&widget=timeline"+tc.query, nil)), tc.query)
+	}
+}
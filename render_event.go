@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
 	"fmt"
@@ -8,12 +9,14 @@ import (
 	"html/template"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"fiatjaf.com/nostr"
 	"fiatjaf.com/nostr/nip05"
 	"fiatjaf.com/nostr/nip19"
+	"fiatjaf.com/nostr/nip29"
 	"github.com/a-h/templ"
 	"github.com/pelletier/go-toml"
 )
@@ -30,6 +33,11 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if strings.HasSuffix(code, ".ics") {
+		renderCalendarICS(w, r, code[:len(code)-4])
+		return
+	}
+
 	// decode the nip19 code we've received
 	prefix, decoded, err := nip19.Decode(code)
 	if err != nil {
@@ -45,6 +53,12 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// or a NIP-29 group address
+		if address, err := nip29.ParseGroupAddress(code); err == nil {
+			renderGroupAddress(w, r, address)
+			return
+		}
+
 		// otherwise error
 		w.Header().Set("Cache-Control", "public, immutable, s-maxage=86400, max-age=86400")
 		log.Warn().Err(err).Str("code", code).Msg("invalid code")
@@ -93,14 +107,11 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 	style := getPreviewStyle(r)
 
 	// gather host
-	host := r.Header.Get("X-Forwarded-Host")
-	if host == "" {
-		host = r.Host
-	}
+	host := requestHost(r)
 
 	useTextImage := false
 
-	if data.event.Kind == 1 || data.event.Kind == 9 || data.event.Kind == 11 || data.event.Kind == 1111 || data.event.Kind == 30023 {
+	if data.event.Kind == 1 || data.event.Kind == 9 || data.event.Kind == 11 || data.event.Kind == 1111 {
 		if data.image == "" && data.video == "" && len(data.event.Content) > 133 {
 			useTextImage = true
 		}
@@ -109,6 +120,12 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 		}
 	} else if data.event.Kind == 20 {
 		useTextImage = false
+	} else if data.event.Kind == 1068 {
+		// polls get their results drawn as bars
+		useTextImage = true
+	} else if data.event.Kind == 30023 || data.event.Kind == 30818 {
+		// articles get a card with their cover, title and summary
+		useTextImage = true
 	}
 
 	if tgiv := r.URL.Query().Get("tgiv"); tgiv == "true" || (style == StyleTelegram && tgiv != "false") {
@@ -175,7 +192,7 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 	textImageURL := ""
 	description := ""
 	if useTextImage {
-		textImageURL = fmt.Sprintf("https://%s/image/%s?%s", host, code, r.URL.RawQuery)
+		textImageURL = fmt.Sprintf("https://%s/image/%s.png?%s", host, code, r.URL.RawQuery)
 		if data.event.subject != "" {
 			if seenOnRelays != "" {
 				description = fmt.Sprintf("%s -- %s", data.event.subject, seenOnRelays)
@@ -236,66 +253,14 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 		titleizedContent = titleizedContent + " ..."
 	}
 
-	// content massaging
-	for i, tag := range data.event.Tags {
-		if len(tag) < 2 {
-			continue
-		}
-
-		placeholderTag := "#[" + fmt.Sprintf("%d", i) + "]"
-		var nreplace nostr.Pointer
-		var err error
-		switch tag[0] {
-		case "p", "P":
-			nreplace, err = nostr.ProfilePointerFromTag(tag)
-		case "e", "E":
-			nreplace, err = nostr.EventPointerFromTag(tag)
-		case "a", "A":
-			nreplace, err = nostr.EventPointerFromTag(tag)
-		default:
-			continue
-		}
-		if err != nil {
-			continue
-		}
-		data.content = strings.ReplaceAll(data.content, placeholderTag, "nostr:"+nip19.EncodePointer(nreplace))
-	}
-	if data.event.Kind == 30023 || data.event.Kind == 30024 {
-		// Remove duplicate title inside the body
-		data.content = strings.ReplaceAll(data.content, "# "+data.event.subject, "")
-		data.content = mdToHTML(data.content, data.templateId == TelegramInstantView)
-	} else if data.event.Kind == 30818 {
-		data.content = djotToHTML(data.content)
-	} else {
-		// first we run basicFormatting, which turns URLs into their appropriate HTML tags
-		data.content = basicFormatting(html.EscapeString(data.content), true, false, false)
-		// then we render quotes as HTML, which will also apply basicFormatting to all the internal quotes
-		data.content = renderQuotesAsHTML(ctx, data.content, data.templateId == TelegramInstantView)
-		// we must do this because inside <blockquotes> we must treat <img>s differently when telegram_instant_view
-	}
+	data.content = renderEventContent(ctx, data)
 
 	w.Header().Set("Content-Type", "text/html")
-	if data.templateId == TelegramInstantView {
-		w.Header().Set("Cache-Control", "no-cache")
-	} else {
-		w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800, stale-while-revalidate=31536000")
-		w.Header().Set("ETag", data.event.ID.Hex())
-	}
+	setEventCacheHeaders(w, data)
 
 	// oembed discovery
-	oembed := ""
-	if data.templateId == Note {
-		oembed = (&url.URL{
-			Scheme: "https",
-			Host:   host,
-			Path:   "/services/oembed",
-			RawQuery: (url.Values{
-				"url": {fmt.Sprintf("https://%s/%s", host, code)},
-			}).Encode(),
-		}).String()
-		w.Header().Add("Link", "<"+oembed+"&format=json>; rel=\"alternate\"; type=\"application/json+oembed\"")
-		w.Header().Add("Link", "<"+oembed+"&format=xml>; rel=\"alternate\"; type=\"text/xml+oembed\"")
-	}
+	oembed := oembedURL(host, "/"+code)
+	addOEmbedLinkHeaders(w, oembed)
 
 	detailsData := DetailsParams{
 		HideDetails:     true,
@@ -307,6 +272,9 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 		SeenOn:          data.event.relays,
 		Metadata:        data.event.author,
 	}
+	if data.naddrNaked != "" && hasHistory(data.event.Kind) {
+		detailsData.HistoryURL = "/" + data.naddrNaked + "/history"
+	}
 
 	opengraph := OpenGraphParams{
 		BigImage:     textImageURL,
@@ -369,6 +337,30 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		// only fetch the thread for people and the thread embed widget, previewers don't need it
+		var thread ThreadParams
+		if (!isEmbed || r.URL.Query().Get("widget") == "thread") && isThreadable(data.event.Kind) && (style == StyleNormal || style == StyleIOS || style == StyleAndroid) {
+			threadCtx, cancel := context.WithTimeout(ctx, time.Second*6)
+			offset, _ := strconv.Atoi(r.URL.Query().Get("replies"))
+			thread.Ancestors = fetchThreadAncestors(threadCtx, data.event)
+			var hasMore bool
+			thread.Replies, hasMore = fetchThreadReplies(threadCtx, data.event, offset)
+			if hasMore {
+				thread.MoreReplies = moreRepliesLink(r.URL.Query(), offset+THREAD_PAGE_SIZE)
+			}
+			cancel()
+		}
+
+		groupName := data.Kind39000Metadata.Name
+		if groupName == "" {
+			groupName = data.Kind39000Metadata.Address.ID
+		}
+
+		var engagement Engagement
+		if data.event.Kind != 7 {
+			engagement = fetchEngagement(ctx, data.event.Event)
+		}
+
 		params := NotePageParams{
 			BaseEventPageParams: baseEventPageParams,
 			OpenGraphParams:     opengraph,
@@ -382,8 +374,10 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 			Details:          detailsData,
 			Content:          template.HTML(content),
 			TitleizedContent: titleizedContent,
-			GroupName:        data.Kind39000Metadata.Name,
-			GroupLink:        data.Kind39000Metadata.Address.Code(),
+			GroupName:        groupName,
+			GroupLink:        groupLink(data.Kind39000Metadata.Address),
+			Thread:           thread,
+			Engagement:       engagement,
 		}
 
 		component = noteTemplate(params, isEmbed)
@@ -391,8 +385,12 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 	case LongForm:
 		if data.cover != "" {
 			opengraph.Image = data.cover
-			opengraph.BigImage = data.cover
-		} else if style == StyleTwitter {
+			if opengraph.BigImage == "" {
+				// when we're not using our card the cover is the next best thing
+				opengraph.BigImage = data.cover
+			}
+		}
+		if style == StyleTwitter {
 			// twitter has started sprinkling this over our image, so let's make it invisible
 			opengraph.SingleTitle = string(INVISIBLE_SPACE)
 		}
@@ -426,6 +424,7 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 			OpenGraphParams:     opengraph,
 			HeadParams: HeadParams{
 				IsProfile:   false,
+				Oembed:      oembed,
 				NaddrNaked:  data.naddrNaked,
 				NeventNaked: data.neventNaked,
 			},
@@ -448,6 +447,7 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 			OpenGraphParams:     opengraph,
 			HeadParams: HeadParams{
 				IsProfile:   false,
+				Oembed:      oembed,
 				NaddrNaked:  data.naddrNaked,
 				NeventNaked: data.neventNaked,
 			},
@@ -472,6 +472,7 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 			OpenGraphParams:     opengraph,
 			HeadParams: HeadParams{
 				IsProfile:   false,
+				Oembed:      oembed,
 				NaddrNaked:  data.naddrNaked,
 				NeventNaked: data.neventNaked,
 			},
@@ -526,6 +527,7 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 			OpenGraphParams:     opengraph,
 			HeadParams: HeadParams{
 				IsProfile:   false,
+				Oembed:      oembed,
 				NaddrNaked:  data.naddrNaked,
 				NeventNaked: data.neventNaked,
 			},
@@ -555,6 +557,7 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 			OpenGraphParams:     opengraph,
 			HeadParams: HeadParams{
 				IsProfile:   false,
+				Oembed:      oembed,
 				NaddrNaked:  data.naddrNaked,
 				NeventNaked: data.neventNaked,
 			},
@@ -589,6 +592,7 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 			OpenGraphParams:     opengraph,
 			HeadParams: HeadParams{
 				IsProfile:   false,
+				Oembed:      oembed,
 				NaddrNaked:  data.naddrNaked,
 				NeventNaked: data.neventNaked,
 			},
@@ -610,6 +614,7 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 			OpenGraphParams:     opengraph,
 			HeadParams: HeadParams{
 				IsProfile:   false,
+				Oembed:      oembed,
 				NaddrNaked:  data.naddrNaked,
 				NeventNaked: data.neventNaked,
 			},
@@ -637,6 +642,7 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 			OpenGraphParams:     opengraph,
 			HeadParams: HeadParams{
 				IsProfile:   false,
+				Oembed:      oembed,
 				NaddrNaked:  data.naddrNaked,
 				NeventNaked: data.neventNaked,
 			},
@@ -682,6 +688,21 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 		}
 		opengraph.Text = groupAbout
 
+		// members and messages are only available from the group relay
+		groupRelay := ""
+		if pointer, ok := decoded.(nostr.EntityPointer); ok && len(pointer.Relays) > 0 {
+			groupRelay = pointer.Relays[0]
+		} else if len(data.event.relays) > 0 {
+			groupRelay = data.event.relays[0]
+		}
+		var admins, members []GroupMember
+		var memberCount int
+		var messages []EnhancedEvent
+		if groupRelay != "" && groupId != "" {
+			admins, members, memberCount = fetchGroupRoles(ctx, groupRelay, data.event.PubKey, groupId)
+			messages = skipSeenEvents(r, fetchGroupMessages(ctx, groupRelay, groupId, parseUntil(r)))
+		}
+
 		params := GroupMetadataPageParams{
 			BaseEventPageParams: baseEventPageParams,
 			OpenGraphParams:     opengraph,
@@ -699,11 +720,201 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 			GroupPicture:     groupPicture,
 			GroupAbout:       groupAbout,
 			Naddr:            naddr,
+			Admins:           admins,
+			Members:          members,
+			MemberCount:      memberCount,
+			Messages:         messages,
+			NextPage:         nextPageURL(r, r.URL.Path, messages, GROUP_PAGE_SIZE),
 			Clients:          generateClientList(int(data.event.Kind), naddr),
 		}
 
 		component = groupMetadataTemplate(params, isEmbed)
 
+	case Zap:
+		opengraph.Superscript = fmt.Sprintf("⚡ %s sats zap on Nostr", formatSats(data.Kind9735Metadata.Amount))
+		opengraph.Subscript = fmt.Sprintf("from %s to %s",
+			data.Kind9735Metadata.senderName(), data.Kind9735Metadata.recipientName())
+		opengraph.Text = data.Kind9735Metadata.Comment
+		if data.Kind9735Metadata.Sender != nil {
+			opengraph.Image = data.Kind9735Metadata.Sender.Picture
+			opengraph.ProxiedImage = "https://" + host + "/proxy?src=" + opengraph.Image
+		}
+
+		params := ZapPageParams{
+			BaseEventPageParams: baseEventPageParams,
+			OpenGraphParams:     opengraph,
+			HeadParams: HeadParams{
+				IsProfile:   false,
+				Oembed:      oembed,
+				NaddrNaked:  data.naddrNaked,
+				NeventNaked: data.neventNaked,
+			},
+			Details: detailsData,
+			Zap:     data.Kind9735Metadata,
+			Clients: generateClientList(int(data.event.Kind), data.nevent),
+		}
+
+		component = zapTemplate(params, isEmbed)
+
+	case Badge:
+		code := data.naddr
+		if data.event.Kind == 8 {
+			code = data.nevent
+		}
+
+		if data.event.Kind == 30008 {
+			opengraph.Superscript = fmt.Sprintf("%d badges on Nostr", len(data.BadgeMetadata.Badges))
+			opengraph.Subscript = "displayed by " + data.event.author.ShortName()
+		} else {
+			opengraph.Superscript = "badge: " + data.BadgeMetadata.Badge.Name
+			if data.event.Kind == 8 {
+				opengraph.Subscript = fmt.Sprintf("awarded to %d people by %s", len(data.BadgeMetadata.Awardees), data.event.author.ShortName())
+			} else {
+				opengraph.Subscript = "by " + data.event.author.ShortName()
+			}
+			opengraph.Text = data.BadgeMetadata.Badge.Description
+			if data.BadgeMetadata.Badge.Image != "" {
+				opengraph.Image = data.BadgeMetadata.Badge.Image
+				opengraph.ProxiedImage = "https://" + host + "/proxy?src=" + opengraph.Image
+			}
+		}
+
+		params := BadgePageParams{
+			BaseEventPageParams: baseEventPageParams,
+			OpenGraphParams:     opengraph,
+			HeadParams: HeadParams{
+				IsProfile:   false,
+				Oembed:      oembed,
+				NaddrNaked:  data.naddrNaked,
+				NeventNaked: data.neventNaked,
+			},
+			Details: detailsData,
+			Badges:  data.BadgeMetadata,
+			Clients: generateClientList(int(data.event.Kind), code),
+		}
+
+		component = badgeTemplate(params, isEmbed)
+
+	case Listing:
+		listing := data.ListingMetadata
+
+		opengraph.Superscript = listingLabel(data.event.Kind) + ": " + listing.Title
+		if listing.Price != "" {
+			opengraph.Subscript = listing.priceString() + " · by " + data.event.author.ShortName()
+			opengraph.PriceAmount = listing.Price
+			opengraph.PriceCurrency = listing.Currency
+		} else {
+			opengraph.Subscript = "by " + data.event.author.ShortName()
+		}
+		if listing.Summary != "" {
+			opengraph.Text = listing.Summary
+		}
+		if len(listing.Images) > 0 {
+			opengraph.Image = listing.Images[0]
+			opengraph.ProxiedImage = "https://" + host + "/proxy?src=" + opengraph.Image
+		}
+
+		params := ListingPageParams{
+			BaseEventPageParams: baseEventPageParams,
+			OpenGraphParams:     opengraph,
+			HeadParams: HeadParams{
+				IsProfile:   false,
+				Oembed:      oembed,
+				NaddrNaked:  data.naddrNaked,
+				NeventNaked: data.neventNaked,
+			},
+			Details: detailsData,
+			Content: template.HTML(data.content),
+			Listing: listing,
+			Clients: generateClientList(int(data.event.Kind), data.naddr),
+		}
+
+		component = listingTemplate(params, isEmbed)
+
+	case Poll:
+		opengraph.Superscript = fmt.Sprintf("poll with %d votes", data.PollMetadata.TotalVoters)
+		opengraph.Subscript = "by " + data.event.author.ShortName()
+		results := make([]string, len(data.PollMetadata.Options))
+		for i, option := range data.PollMetadata.Options {
+			results[i] = fmt.Sprintf("%s: %d%%", option.Label, data.PollMetadata.percent(option))
+		}
+		opengraph.Text = data.event.Content + "\n" + strings.Join(results, "\n")
+
+		params := PollPageParams{
+			BaseEventPageParams: baseEventPageParams,
+			OpenGraphParams:     opengraph,
+			HeadParams: HeadParams{
+				IsProfile:   false,
+				Oembed:      oembed,
+				NaddrNaked:  data.naddrNaked,
+				NeventNaked: data.neventNaked,
+			},
+			Details: detailsData,
+			Content: template.HTML(data.content),
+			Poll:    data.PollMetadata,
+			Clients: generateClientList(int(data.event.Kind), data.nevent),
+		}
+
+		component = pollTemplate(params, isEmbed)
+
+	case Git:
+		code := data.nevent
+		if data.event.Kind == 30617 {
+			code = data.naddr
+			opengraph.Superscript = "git repository: " + data.GitMetadata.Repository.Name
+			opengraph.Subscript = "by " + data.event.author.ShortName()
+			opengraph.Text = data.GitMetadata.Repository.Description
+		} else {
+			if data.event.Kind == 1617 {
+				opengraph.Superscript = "patch: " + data.GitMetadata.Subject
+			} else {
+				opengraph.Superscript = "issue: " + data.GitMetadata.Subject
+			}
+			if data.GitMetadata.Repository.Name != "" {
+				opengraph.Subscript = fmt.Sprintf("%s on %s by %s", data.GitMetadata.Status, data.GitMetadata.Repository.Name, data.event.author.ShortName())
+			} else {
+				opengraph.Subscript = fmt.Sprintf("%s by %s", data.GitMetadata.Status, data.event.author.ShortName())
+			}
+		}
+
+		params := GitPageParams{
+			BaseEventPageParams: baseEventPageParams,
+			OpenGraphParams:     opengraph,
+			HeadParams: HeadParams{
+				IsProfile:   false,
+				Oembed:      oembed,
+				NaddrNaked:  data.naddrNaked,
+				NeventNaked: data.neventNaked,
+			},
+			Details: detailsData,
+			Content: template.HTML(data.content),
+			Git:     data.GitMetadata,
+			Clients: generateClientList(int(data.event.Kind), code),
+		}
+
+		component = gitTemplate(params, isEmbed)
+
+	case CalendarCollection:
+		opengraph.Superscript = "calendar: " + data.CalendarMetadata.Title
+		opengraph.Subscript = fmt.Sprintf("by %s, %d upcoming events", data.event.author.ShortName(), len(data.CalendarMetadata.Upcoming))
+
+		params := CalendarCollectionPageParams{
+			BaseEventPageParams: baseEventPageParams,
+			OpenGraphParams:     opengraph,
+			HeadParams: HeadParams{
+				IsProfile:   false,
+				Oembed:      oembed,
+				NaddrNaked:  data.naddrNaked,
+				NeventNaked: data.neventNaked,
+			},
+			Details:  detailsData,
+			Content:  template.HTML(data.content),
+			Calendar: data.CalendarMetadata,
+			Clients:  generateClientList(int(data.event.Kind), data.naddr),
+		}
+
+		component = calendarCollectionTemplate(params, isEmbed)
+
 	case Other:
 		detailsData.HideDetails = false // always open this since we know nothing else about the event
 
@@ -711,6 +922,7 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 			BaseEventPageParams: baseEventPageParams,
 			HeadParams: HeadParams{
 				IsProfile:   false,
+				Oembed:      oembed,
 				NaddrNaked:  data.naddrNaked,
 				NeventNaked: data.neventNaked,
 			},
@@ -733,3 +945,66 @@ func renderEvent(w http.ResponseWriter, r *http.Request) {
 	}
 	return
 }
+
+// renderEventContent turns the raw content of an event into the HTML we show on its page,
+// the API returns this same HTML
+func renderEventContent(ctx context.Context, data Data) string {
+	content := data.content
+	for i, tag := range data.event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+
+		placeholderTag := "#[" + fmt.Sprintf("%d", i) + "]"
+		var nreplace nostr.Pointer
+		var err error
+		switch tag[0] {
+		case "p", "P":
+			nreplace, err = nostr.ProfilePointerFromTag(tag)
+		case "e", "E":
+			nreplace, err = nostr.EventPointerFromTag(tag)
+		case "a", "A":
+			nreplace, err = nostr.EventPointerFromTag(tag)
+		default:
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		content = strings.ReplaceAll(content, placeholderTag, "nostr:"+nip19.EncodePointer(nreplace))
+	}
+	if data.event.Kind == 30023 || data.event.Kind == 30024 || data.event.Kind == 30402 || data.event.Kind == 1621 {
+		// Remove duplicate title inside the body
+		content = strings.ReplaceAll(content, "# "+data.event.subject, "")
+		content = mdToHTML(content, data.templateId == TelegramInstantView)
+	} else if data.event.Kind == 30818 {
+		content = djotToHTML(content)
+	} else {
+		// first we run basicFormatting, which turns URLs into their appropriate HTML tags
+		content = basicFormatting(html.EscapeString(content), true, false, false)
+		// then we render quotes as HTML, which will also apply basicFormatting to all the internal quotes
+		content = renderQuotesAsHTML(ctx, content, data.templateId == TelegramInstantView)
+		// we must do this because inside <blockquotes> we must treat <img>s differently when telegram_instant_view
+	}
+
+	return content
+}
+
+// setEventCacheHeaders sets how long the page or API response for an event can be cached
+func setEventCacheHeaders(w http.ResponseWriter, data Data) {
+	switch data.templateId {
+	case TelegramInstantView:
+		w.Header().Set("Cache-Control", "no-cache")
+	case LiveEvent:
+		// the stream goes live and ends and the chat moves fast
+		w.Header().Set("Cache-Control", "public, s-maxage=60, max-age=60, stale-while-revalidate=3600")
+	case Note, Poll, Git, CalendarCollection, GroupMetadata:
+		// notes show replies and engagement counts, polls show their tallies, repositories show
+		// their issues and patches, calendars show their events and groups show their messages,
+		// all of which keep changing
+		w.Header().Set("Cache-Control", "public, s-maxage=300, max-age=300, stale-while-revalidate=604800")
+	default:
+		w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800, stale-while-revalidate=31536000")
+		w.Header().Set("ETag", data.event.ID.Hex())
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+)
+
+func renderHashtagPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tag := r.URL.Path[3:]
+	isEmbed := r.URL.Query().Get("embed") != ""
+
+	isSitemap := false
+	if strings.HasSuffix(tag, ".xml") {
+		tag = tag[:len(tag)-4]
+		isSitemap = true
+	}
+
+	isRSS := false
+	if strings.HasSuffix(tag, ".rss") {
+		tag = tag[:len(tag)-4]
+		isRSS = true
+	}
+
+	isJSON := false
+	if strings.HasSuffix(tag, ".json") {
+		tag = tag[:len(tag)-5]
+		isJSON = true
+	}
+
+	// feeds can be restricted to some kinds, like ?kinds=30023 for articles
+	var kinds []nostr.Kind
+	if isRSS || isJSON {
+		kinds = feedKinds(r)
+	}
+
+	normalized := normalizeHashtag(tag)
+	if normalized == "" || strings.Contains(normalized, "/") {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	if normalized != tag {
+		target := "/t/" + url.PathEscape(normalized) + r.URL.Path[3+len(tag):]
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusFound)
+		return
+	}
+
+	if isHashtagProhibited(tag) {
+		w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
+		http.Error(w, "hashtag not allowed", http.StatusNotFound)
+		return
+	}
+
+	limit := 50
+	if isSitemap {
+		limit = 500
+	} else if isEmbed {
+		limit = embedLimit(r)
+	}
+	lastNotes := skipSeenEvents(r, hashtagLastNotes(ctx, tag, kinds, limit, parseUntil(r), !isSitemap))
+
+	lastEventAt := time.Now()
+	if len(lastNotes) > 0 {
+		lastEventAt = lastNotes[0].CreatedAt.Time()
+		w.Header().Set("Cache-Control", "public, max-age=3600, s-maxage=3600")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=600, s-maxage=600")
+	}
+
+	var err error
+	if isSitemap {
+		w.Header().Add("content-type", "text/xml")
+
+		var buf bytes.Buffer
+		buf.WriteString(XML_HEADER)
+		err = SitemapTemplate.Render(&buf, &SitemapPage{
+			Host:       s.Domain,
+			ModifiedAt: lastEventAt.Format("2006-01-02T15:04:05Z07:00"),
+			LastNotes:  lastNotes,
+			Hashtag:    tag,
+		})
+		if err == nil {
+			w.Write(buf.Bytes())
+		}
+
+	} else if isRSS {
+		w.Header().Add("content-type", "text/xml")
+
+		var buf bytes.Buffer
+		buf.WriteString(XML_HEADER)
+		err = RSSTemplate.Render(&buf, &RSSPage{
+			Host:       s.Domain,
+			ModifiedAt: lastEventAt.Format("2006-01-02T15:04:05Z07:00"),
+			Title:      feedTitle(kinds),
+			LastNotes:  lastNotes,
+			Hashtag:    tag,
+			NextPage:   nextPageURL(r, "https://"+s.Domain+r.URL.Path, lastNotes, limit),
+		})
+		if err == nil {
+			w.Write(buf.Bytes())
+		}
+
+	} else if isJSON {
+		title := feedTitle(kinds)
+		if title == "" {
+			title = "notes"
+		}
+		err = renderJSONFeed(w, JSONFeed{
+			Title:       "Nostr " + title + " tagged #" + tag,
+			HomePageURL: "https://" + s.Domain + "/t/" + url.PathEscape(tag),
+			FeedURL:     "https://" + s.Domain + r.URL.RequestURI(),
+			NextURL:     nextPageURL(r, "https://"+s.Domain+r.URL.Path, lastNotes, limit),
+			Items:       jsonFeedItems(lastNotes),
+		})
+
+	} else if isEmbed {
+		err = embeddedTimelineTemplate(EmbeddedTimelineParams{
+			Title:    "#" + tag,
+			Subtitle: "Nostr notes tagged #" + tag,
+			Path:     "/t/" + url.PathEscape(tag),
+			Notes:    lastNotes,
+		}).Render(ctx, w)
+
+	} else {
+		err = hashtagTemplate(HashtagPageParams{
+			HeadParams: HeadParams{IsProfile: false},
+			Hashtag:    tag,
+			LastNotes:  lastNotes,
+			NextPage:   nextPageURL(r, r.URL.Path, lastNotes, limit),
+			ModifiedAt: lastEventAt.Format("2006-01-02T15:04:05Z07:00"),
+		}).Render(ctx, w)
+	}
+
+	if err != nil {
+		log.Warn().Err(err).Msg("error rendering tmpl")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+func renderHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	code := r.PathValue("code")
+
+	prefix, value, err := nip19.Decode(code)
+	if err != nil || prefix != "naddr" {
+		http.NotFound(w, r)
+		return
+	}
+	pointer := value.(nostr.EntityPointer)
+	if !hasHistory(pointer.Kind) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if banned, reason := isPubkeyBanned(pointer.PublicKey); banned {
+		w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
+		log.Warn().Str("pubkey", pointer.PublicKey.Hex()).Str("reason", reason).Msg("pubkey banned")
+		http.Error(w, "pubkey banned", http.StatusNotFound)
+		return
+	}
+
+	revisions := fetchRevisions(ctx, pointer)
+	revisions = slices.DeleteFunc(revisions, func(rev Revision) bool {
+		banned, _ := isEventBanned(rev.Event.ID)
+		return banned
+	})
+	if len(revisions) == 0 {
+		w.Header().Set("Cache-Control", "public, s-maxage=600, max-age=600")
+		http.Error(w, "no versions found", http.StatusNotFound)
+		return
+	}
+
+	// by default compare the latest version with the one before it
+	fromIdx, toIdx := -1, -1
+	if len(revisions) > 1 {
+		fromIdx, toIdx = 1, 0
+	}
+	if to := r.URL.Query().Get("to"); to != "" {
+		toIdx = slices.IndexFunc(revisions, func(rev Revision) bool { return rev.ID == to })
+		fromIdx = -1
+		if toIdx != -1 && toIdx+1 < len(revisions) {
+			fromIdx = toIdx + 1
+		}
+	}
+	if from := r.URL.Query().Get("from"); from != "" {
+		fromIdx = slices.IndexFunc(revisions, func(rev Revision) bool { return rev.ID == from })
+	}
+
+	params := HistoryPageParams{
+		HeadParams: HeadParams{IsProfile: false, NaddrNaked: nip19.EncodeNaddr(pointer.PublicKey, pointer.Kind, pointer.Identifier, nil)},
+		Naddr:      code,
+		Author:     sys.FetchProfileMetadata(ctx, pointer.PublicKey),
+		Revisions:  revisions,
+	}
+	if fromIdx != -1 && toIdx != -1 && fromIdx != toIdx {
+		params.From = revisions[fromIdx].ID
+		params.To = revisions[toIdx].ID
+		params.Diff = revisionDiff(revisions[fromIdx], revisions[toIdx])
+	}
+
+	w.Header().Set("Cache-Control", "public, s-maxage=3600, max-age=3600")
+	err = historyTemplate(params).Render(ctx, w)
+	if err != nil {
+		log.Warn().Err(err).Msg("error rendering tmpl")
+	}
+}
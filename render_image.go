@@ -14,6 +14,8 @@ import (
 	"strings"
 	"time"
 
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip05"
 	"fiatjaf.com/nostr/sdk"
 	"github.com/fogleman/gg"
 	"github.com/go-text/typesetting/shaping"
@@ -30,6 +32,7 @@ var (
 	BACKGROUND     = color.RGBA{23, 23, 23, 255}
 	BAR_BACKGROUND = color.RGBA{10, 10, 10, 255}
 	FOREGROUND     = color.RGBA{255, 230, 238, 255}
+	STRONGPINK     = color.RGBA{227, 42, 109, 255}
 )
 
 //go:embed fonts/*
@@ -50,10 +53,25 @@ func renderImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// trim fake extensions
-	extensions := []string{".png", ".jpg", ".jpeg"}
-	for _, ext := range extensions {
-		code = strings.TrimSuffix(code, ext)
+	// trim fake extensions, they also choose the format we'll encode the image in
+	var ext string
+	for _, e := range []string{".png", ".jpg", ".jpeg", ".webp"} {
+		if strings.HasSuffix(code, e) {
+			code = strings.TrimSuffix(code, e)
+			ext = e
+		}
+	}
+	format := getImageFormat(ext, r.Header.Get("Accept"))
+	if ext == "" {
+		w.Header().Set("Vary", "Accept")
+	}
+	style := getPreviewStyle(r)
+
+	// profiles get their own card
+	if strings.HasPrefix(code, "npub1") || strings.HasPrefix(code, "nprofile1") ||
+		(nip05.IsValidIdentifier(code) && strings.Contains(code, ".")) {
+		renderProfileImage(w, r, code, style, format)
+		return
 	}
 
 	event, _, err := sys.FetchSpecificEventFromInput(ctx, code, sdk.FetchSpecificEventParameters{})
@@ -71,6 +89,12 @@ func renderImage(w http.ResponseWriter, r *http.Request) {
 
 	author := getMetadata(ctx, *event)
 
+	// articles get a card with their cover, title and summary instead of having their text drawn
+	if event.Kind == 30023 || event.Kind == 30818 {
+		renderArticleImage(w, r, event, author, style, format)
+		return
+	}
+
 	content := event.Content
 	content = strings.Replace(content, "\r\n", "\n", -1)
 	content = multiNewlineRe.ReplaceAllString(content, "\n\n")
@@ -89,20 +113,30 @@ func renderImage(w http.ResponseWriter, r *http.Request) {
 		string(INVISIBLE_SPACE),
 	)
 
-	img, err := drawImage(ctx, paragraphs, getPreviewStyle(r), author, event.CreatedAt.Time())
+	var poll *PollMetadata
+	if event.Kind == 1068 {
+		metadata := parsePoll(event)
+		tallyPoll(ctx, event, &metadata)
+		poll = &metadata
+	}
+
+	engagement := fetchEngagement(ctx, event)
+
+	img, err := drawImage(ctx, paragraphs, style, author, event.CreatedAt.Time(), poll, engagement)
 	if err != nil {
 		log.Warn().Err(err).Msg("failed to draw paragraphs as image")
 		http.Error(w, "error writing image!", 500)
 		return
 	}
 
-	w.Header().Set("Content-Type", "image/png")
-	w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
-
-	if err := png.Encode(w, img); err != nil {
-		log.Printf("error encoding image: %s", err)
-		return
+	if engagement.isEmpty() && poll == nil {
+		w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
+	} else {
+		// the counts and the poll tallies keep changing
+		w.Header().Set("Cache-Control", "public, s-maxage=300, max-age=300")
 	}
+
+	writeImage(ctx, w, img, format, style)
 }
 
 func drawImage(
@@ -111,6 +145,8 @@ func drawImage(
 	style Style,
 	metadata sdk.ProfileMetadata,
 	date time.Time,
+	poll *PollMetadata,
+	engagement Engagement,
 ) (image image.Image, err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -120,8 +156,7 @@ func drawImage(
 	}()
 
 	fontSize := 25
-	width := 700
-	height := 525
+	width, height := getImageSize(style)
 	paddingLeft := 25
 	gradientRectHeight := 140
 	barHeight := 70
@@ -129,17 +164,26 @@ func drawImage(
 	switch style {
 	case StyleTelegram:
 		paddingLeft += 10
-		width -= 10
-	case StyleTwitter:
-		height = width * 268 / 512
 	case StyleFacebook:
-		height = width * 355 / 680
 		paddingLeft = 180
 		barScale = 0.55
 		barHeight = int(float64(barHeight) * barScale)
 		fontSize = 18
 	}
 
+	// polls have their options drawn as bars below the text
+	pollHeight := 0
+	pollRowHeight := int(float64(fontSize) * 1.8)
+	if poll != nil {
+		pollHeight = min(len(poll.Options), 4)*pollRowHeight + 10
+	}
+
+	// a line with the engagement counts right above the bottom bar
+	engagementHeight := 0
+	if !engagement.isEmpty() {
+		engagementHeight = fontSize + 14
+	}
+
 	img := gg.NewContext(width, height)
 	img.SetColor(BACKGROUND)
 	img.Clear()
@@ -149,7 +193,7 @@ func drawImage(
 	addedSize := 0
 	zoom := 1.0
 	textFontSize := fontSize
-	if np := len(paragraphs); !containsMedia(paragraphs) && np < 6 {
+	if np := len(paragraphs); !containsMedia(paragraphs) && np < 6 && poll == nil {
 		nchars := 0
 		blankLines := 0
 		for _, par := range paragraphs {
@@ -164,9 +208,18 @@ func drawImage(
 		textFontSize = int(float64(fontSize + addedSize))
 	}
 	textImg, overflowingText := drawParagraphs(ctx,
-		paragraphs, textFontSize, width-paddingLeft*2, height-20-barHeight)
+		paragraphs, textFontSize, width-paddingLeft*2, height-20-barHeight-pollHeight-engagementHeight)
 	img.DrawImage(textImg, paddingLeft, 20)
 
+	if poll != nil {
+		drawPollBars(ctx, img, poll, fontSize, paddingLeft, height-barHeight-pollHeight-engagementHeight, width-paddingLeft*2, pollRowHeight)
+	}
+
+	if engagementHeight > 0 {
+		textImg, _ := drawParagraphs(ctx, []string{engagement.iconSummary()}, fontSize*3/4, width-paddingLeft*2, engagementHeight)
+		img.DrawImage(textImg, paddingLeft, height-barHeight-engagementHeight)
+	}
+
 	// font for writing the date
 	img.SetFontFace(truetype.NewFace(dateFont, &truetype.Options{
 		Size:    (6 * barScale),
@@ -181,7 +234,7 @@ func drawImage(
 
 	// a rectangle at the bottom with a gradient from black to transparent
 	if overflowingText {
-		gradientRectY := height - barHeight - gradientRectHeight
+		gradientRectY := height - barHeight - pollHeight - engagementHeight - gradientRectHeight
 		for y := 0; y < gradientRectHeight; y++ {
 			alpha := uint8(255 * (math.Pow(float64(y)/float64(gradientRectHeight), 2)))
 			img.SetRGBA255(int(BACKGROUND.R), int(BACKGROUND.G), int(BACKGROUND.B), int(alpha))
@@ -227,6 +280,400 @@ func drawImage(
 	}
 
 	// bottom bar logo
+	stampWidth, stampY := drawBarLogo(img, width, height, barHeight, paddingLeft)
+
+	// draw event date
+	formattedDate := date.Format("Jan 02, 2006")
+	img.SetColor(color.RGBA{160, 160, 160, 255})
+	img.DrawStringWrapped(formattedDate, float64(width-paddingLeft-stampWidth-250), float64(stampY)+3, 0, 0, float64(240), 1.5, gg.AlignRight)
+
+	return img.Image(), nil
+}
+
+func renderArticleImage(w http.ResponseWriter, r *http.Request, event *nostr.Event, author sdk.ProfileMetadata, style Style, format ImageFormat) {
+	ctx := r.Context()
+	ee := NewEnhancedEventWithoutMetadata(*event)
+
+	title := ee.subject
+	if title == "" {
+		title = event.Tags.GetD()
+	}
+
+	summary := ee.summary
+	if summary == "" {
+		summary = event.Content
+		if text, err := markdownExtractor.PlainText(summary); err == nil {
+			summary = *text
+		}
+	}
+	summary = strings.Join(strings.Fields(summary), " ")
+	if len(summary) > 400 {
+		summary = summary[0:400]
+	}
+
+	cover := ee.Cover()
+	img, hasCover, err := drawArticleImage(ctx, title, summary, cover, style, author, event.CreatedAt.Time(), readingTime(event.Content))
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to draw article card")
+		http.Error(w, "error writing image!", 500)
+		return
+	}
+
+	// articles and wiki pages can be edited, so these cards can't be cached forever,
+	// and when the author or the cover didn't load we want to try again soon
+	if author.Event == nil || (cover != "" && !hasCover) {
+		w.Header().Set("Cache-Control", "public, s-maxage=60, max-age=60")
+	} else {
+		w.Header().Set("Cache-Control", "public, s-maxage=3600, max-age=3600")
+	}
+	writeImage(ctx, w, img, format, style)
+}
+
+// readingTime is an estimate in minutes, assuming 200 words per minute
+func readingTime(content string) int {
+	return max(1, (len(strings.Fields(content))+199)/200)
+}
+
+func drawArticleImage(
+	ctx context.Context,
+	title string,
+	summary string,
+	cover string,
+	style Style,
+	metadata sdk.ProfileMetadata,
+	date time.Time,
+	minutes int,
+) (out image.Image, hasCover bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while drawing article image")
+			log.Warn().Interface("r", r).Msg("panic while drawing article image")
+		}
+	}()
+
+	fontSize := 25
+	width, height := getImageSize(style)
+	paddingLeft := 25
+	barHeight := 70
+
+	// on twitter and facebook the cover fills the whole card with the text on top of it,
+	// everywhere else the cover goes on top with the text below
+	overlay := false
+	switch style {
+	case StyleTelegram:
+		paddingLeft += 10
+	case StyleTwitter:
+		overlay = true
+	case StyleFacebook:
+		fontSize = 20
+		overlay = true
+	}
+	contentHeight := height - barHeight
+
+	img := gg.NewContext(width, height)
+	img.SetColor(BACKGROUND)
+	img.Clear()
+
+	textTop := paddingLeft
+	if cover != "" {
+		if coverImage, err := fetchImageFromURL(ctx, cover); err == nil {
+			hasCover = true
+			if overlay {
+				img.DrawImage(cropToFill(coverImage, width, contentHeight), 0, 0)
+
+				// darken the bottom of the cover so the text can be read
+				gradientRectHeight := contentHeight * 3 / 4
+				gradientRectY := contentHeight - gradientRectHeight
+				for y := 0; y < gradientRectHeight; y++ {
+					alpha := uint8(235 * (math.Pow(float64(y)/float64(gradientRectHeight), 1.5)))
+					img.SetRGBA255(int(BACKGROUND.R), int(BACKGROUND.G), int(BACKGROUND.B), int(alpha))
+					img.DrawRectangle(0, float64(gradientRectY+y), float64(width), 1)
+					img.Fill()
+				}
+			} else {
+				coverHeight := contentHeight * 9 / 20
+				img.DrawImage(cropToFill(coverImage, width, coverHeight), 0, 0)
+				textTop = coverHeight + 20
+			}
+		}
+	}
+
+	// title and summary
+	titleFontSize := fontSize * 3 / 2
+	titleMaxLines := 3
+	if hasCover {
+		titleMaxLines = 2
+	}
+	titleImg, titleHeight := drawTextLines(title, titleFontSize, FOREGROUND, width-paddingLeft*2, titleMaxLines)
+
+	summaryFontSize := fontSize * 4 / 5
+	summaryTop := textTop + titleHeight + 10
+	summaryMaxLines := (contentHeight - 20 - summaryTop) / (summaryFontSize * 12 / 10)
+	if overlay && hasCover {
+		summaryMaxLines = 2
+	}
+	summaryHeight := 0
+	var summaryImg image.Image
+	if summary != "" && summaryMaxLines > 0 {
+		summaryImg, summaryHeight = drawTextLines(summary, summaryFontSize, color.RGBA{190, 190, 190, 255}, width-paddingLeft*2, summaryMaxLines)
+	}
+
+	if overlay && hasCover {
+		// anchor the text to the bottom of the cover
+		textTop = contentHeight - 20 - summaryHeight - 10 - titleHeight
+		summaryTop = textTop + titleHeight + 10
+	}
+	img.DrawImage(titleImg, paddingLeft, textTop)
+	if summaryImg != nil {
+		img.DrawImage(summaryImg, paddingLeft, summaryTop)
+	}
+
+	// black bar at the bottom
+	img.SetColor(BAR_BACKGROUND)
+	img.DrawRectangle(0, float64(contentHeight), float64(width), float64(barHeight))
+	img.Fill()
+
+	// author picture and name
+	picHeight := barHeight - 20
+	if metadata.Picture != "" {
+		authorImage, err := fetchImageFromURL(ctx, metadata.Picture)
+		if err == nil {
+			resizedAuthorImage := resize.Resize(uint(picHeight), uint(picHeight), roundImage(cropToSquare(authorImage)), resize.Lanczos3)
+			img.DrawImage(resizedAuthorImage, paddingLeft, contentHeight+10)
+		}
+	}
+	authorTextX := paddingLeft + picHeight + 15
+	nameImg, _ := drawTextLines(metadata.ShortName(), fontSize*4/5, color.White, width/2-authorTextX, 1)
+	img.DrawImage(nameImg, authorTextX, contentHeight+(barHeight-fontSize)/2-2)
+
+	// bottom bar logo
+	stampWidth, stampY := drawBarLogo(img, width, height, barHeight, paddingLeft)
+
+	// date and reading time
+	img.SetFontFace(truetype.NewFace(dateFont, &truetype.Options{
+		Size:    6,
+		DPI:     260,
+		Hinting: xfont.HintingFull,
+	}))
+	img.SetColor(color.RGBA{160, 160, 160, 255})
+	img.DrawStringWrapped(
+		fmt.Sprintf("%s · %d min read", date.Format("Jan 02, 2006"), minutes),
+		float64(width-paddingLeft-stampWidth-300), float64(stampY)+3, 0, 0, float64(290), 1.5, gg.AlignRight,
+	)
+
+	return img.Image(), hasCover, nil
+}
+
+func renderProfileImage(w http.ResponseWriter, r *http.Request, code string, style Style, format ImageFormat) {
+	ctx := r.Context()
+
+	pp := sdk.InputToProfile(ctx, code)
+	if pp == nil {
+		w.Header().Set("Cache-Control", "public, immutable, s-maxage=86400, max-age=86400")
+		log.Warn().Str("code", code).Msg("invalid profile code on render_image")
+		http.Error(w, "invalid profile code", http.StatusNotFound)
+		return
+	}
+
+	if banned, reason := isPubkeyBanned(pp.PublicKey); banned {
+		deleteAllEventsFromPubKey(pp.PublicKey)
+		w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
+		log.Warn().Str("pubkey", pp.PublicKey.Hex()).Str("reason", reason).Msg("pubkey banned")
+		http.Error(w, "pubkey banned", http.StatusNotFound)
+		return
+	}
+
+	profileCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	profile := sys.FetchProfileMetadata(profileCtx, pp.PublicKey)
+
+	if reason := profileBlockedReason(ctx, profile); reason != "" {
+		w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
+		http.Error(w, reason, http.StatusNotFound)
+		return
+	}
+
+	follows := fetchFollowCounts(ctx, pp.PublicKey)
+
+	img, err := drawProfileImage(ctx, profile, style, follows)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to draw profile card")
+		http.Error(w, "error writing image!", 500)
+		return
+	}
+
+	if profile.Event == nil {
+		w.Header().Set("Cache-Control", "public, s-maxage=5, max-age=5")
+	} else {
+		w.Header().Set("Cache-Control", "public, s-maxage=3600, max-age=3600")
+	}
+
+	writeImage(ctx, w, img, format, style)
+}
+
+func drawProfileImage(
+	ctx context.Context,
+	profile sdk.ProfileMetadata,
+	style Style,
+	follows FollowCounts,
+) (image image.Image, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while drawing profile image")
+			log.Warn().Interface("r", r).Msg("panic while drawing profile image")
+		}
+	}()
+
+	fontSize := 22
+	width, height := getImageSize(style)
+	paddingLeft := 25
+	barHeight := 70
+	switch style {
+	case StyleTelegram:
+		paddingLeft += 10
+	case StyleFacebook:
+		fontSize = 18
+	}
+	bannerHeight := height * 2 / 5
+	avatarSize := height / 4
+	statsHeight := fontSize + 14
+
+	img := gg.NewContext(width, height)
+	img.SetColor(BACKGROUND)
+	img.Clear()
+
+	// banner at the top, cropped to cover the whole area
+	img.SetColor(BAR_BACKGROUND)
+	img.DrawRectangle(0, 0, float64(width), float64(bannerHeight))
+	img.Fill()
+	if profile.Banner != "" {
+		if bannerImage, err := fetchImageFromURL(ctx, profile.Banner); err == nil {
+			img.DrawImage(cropToFill(bannerImage, width, bannerHeight), 0, 0)
+		}
+	}
+
+	// avatar overlapping the bottom of the banner, with a border of the background color
+	avatarY := bannerHeight - avatarSize/2
+	img.SetColor(BACKGROUND)
+	img.DrawCircle(float64(paddingLeft+avatarSize/2), float64(avatarY+avatarSize/2), float64(avatarSize/2+5))
+	img.Fill()
+	if profile.Picture != "" {
+		if authorImage, err := fetchImageFromURL(ctx, profile.Picture); err == nil {
+			resizedAuthorImage := resize.Resize(uint(avatarSize), uint(avatarSize), roundImage(cropToSquare(authorImage)), resize.Lanczos3)
+			img.DrawImage(resizedAuthorImage, paddingLeft, avatarY)
+		}
+	}
+
+	// name and nip-05 to the right of the avatar
+	nameX := paddingLeft + avatarSize + 20
+	nameY := bannerHeight + 8
+	nameImg, nameHeight := drawTextLines(profile.ShortName(), fontSize*3/2, FOREGROUND, width-nameX-paddingLeft, 1)
+	img.DrawImage(nameImg, nameX, nameY)
+	if profile.NIP05 != "" {
+		nip05Img, _ := drawTextLines(strings.TrimPrefix(profile.NIP05, "_@"), fontSize*3/4, color.RGBA{160, 160, 160, 255}, width-nameX-paddingLeft, 1)
+		img.DrawImage(nip05Img, nameX, nameY+nameHeight)
+	}
+
+	// about text below the avatar, without any media
+	aboutY := avatarY + avatarSize + 15
+	aboutHeight := height - barHeight - statsHeight - aboutY
+	if profile.About != "" && aboutHeight > fontSize {
+		about := strings.Replace(profile.About, "\r\n", "\n", -1)
+		about = multiNewlineRe.ReplaceAllString(about, "\n")
+		about = strings.Replace(about, "\t", "  ", -1)
+		about = shortenURLs(about, true)
+		if len(about) > 400 {
+			about = about[0:400]
+		}
+		paragraphs := make([]string, 0, 6)
+		for _, par := range strings.Split(about, "\n") {
+			if !isMediaURL(par) {
+				paragraphs = append(paragraphs, par)
+			}
+		}
+		paragraphs = replaceUserReferencesWithNames(ctx, paragraphs, string(INVISIBLE_SPACE))
+
+		textImg, overflowingText := drawParagraphs(ctx, paragraphs, fontSize, width-paddingLeft*2, aboutHeight)
+		img.DrawImage(textImg, paddingLeft, aboutY)
+
+		if overflowingText {
+			gradientRectHeight := min(aboutHeight, 60)
+			gradientRectY := aboutY + aboutHeight - gradientRectHeight
+			for y := 0; y < gradientRectHeight; y++ {
+				alpha := uint8(255 * (math.Pow(float64(y)/float64(gradientRectHeight), 2)))
+				img.SetRGBA255(int(BACKGROUND.R), int(BACKGROUND.G), int(BACKGROUND.B), int(alpha))
+				img.DrawRectangle(0, float64(gradientRectY+y), float64(width), 1)
+				img.Fill()
+			}
+		}
+	}
+
+	// following and followers counts
+	stats := fmt.Sprintf("%s following   %s followers", formatSats(int64(follows.Following)), follows.followersStr())
+	statsImg, _ := drawTextLines(stats, fontSize*3/4, STRONGPINK, width-paddingLeft*2, 1)
+	img.DrawImage(statsImg, paddingLeft, height-barHeight-statsHeight+4)
+
+	// black bar at the bottom
+	img.SetColor(BAR_BACKGROUND)
+	img.DrawRectangle(0, float64(height-barHeight), float64(width), float64(barHeight))
+	img.Fill()
+
+	// bottom bar logo
+	_, stampY := drawBarLogo(img, width, height, barHeight, paddingLeft)
+
+	// npub on the left of the bar
+	img.SetFontFace(truetype.NewFace(dateFont, &truetype.Options{
+		Size:    6,
+		DPI:     260,
+		Hinting: xfont.HintingFull,
+	}))
+	img.SetColor(color.RGBA{160, 160, 160, 255})
+	img.DrawStringWrapped(profile.NpubShort(), float64(paddingLeft), float64(stampY)+3, 0, 0, float64(width/2), 1.5, gg.AlignLeft)
+
+	return img.Image(), nil
+}
+
+// drawTextLines draws text in a single color wrapped at width, anything beyond maxLines is cut.
+// it returns the image and the height taken by the lines that were drawn.
+func drawTextLines(text string, fontSize int, textColor color.Color, width int, maxLines int) (image.Image, int) {
+	lineHeight := fontSize * 12 / 10
+	img := image.NewNRGBA(image.Rect(0, 0, width, lineHeight*maxLines+fontSize/2))
+
+	rawText := []rune(text)
+	shapedRunes, emojiMask, hlMask := shapeText(rawText, fontSize)
+
+	var wrapper shaping.LineWrapper
+	it := shaping.NewSliceIterator([]shaping.Output{shapedRunes})
+	lines, _ := wrapper.WrapParagraph(shaping.WrapConfig{}, width, rawText, it)
+
+	totalCharsWritten := 0
+	yPos := fontSize
+	for i, line := range lines {
+		if i >= maxLines {
+			break
+		}
+		for _, out := range line {
+			charsWritten, _ := drawShapedBlockAt(
+				img,
+				fontSize,
+				[4]color.Color{textColor, textColor, textColor, textColor},
+				out,
+				emojiMask,
+				hlMask,
+				totalCharsWritten,
+				0,
+				yPos,
+			)
+			totalCharsWritten += charsWritten
+		}
+		yPos += lineHeight
+	}
+
+	return img, min(len(lines), maxLines) * lineHeight
+}
+
+// drawBarLogo draws the njump logo on the right side of the bottom bar, returning its width and y position
+func drawBarLogo(img *gg.Context, width, height, barHeight, paddingLeft int) (int, int) {
 	logo, _ := static.ReadFile("static/logo.png")
 	stampImg, _ := png.Decode(bytes.NewBuffer(logo))
 	stampRatio := float64(stampImg.Bounds().Dx() / stampImg.Bounds().Dy())
@@ -236,13 +683,31 @@ func drawImage(
 	stampX := width - int(stampWidth) - paddingLeft
 	stampY := height - barHeight + (barHeight-int(stampHeight))/2
 	img.DrawImage(resizedStampImg, stampX, stampY)
+	return int(stampWidth), stampY
+}
 
-	// draw event date
-	formattedDate := date.Format("Jan 02, 2006")
-	img.SetColor(color.RGBA{160, 160, 160, 255})
-	img.DrawStringWrapped(formattedDate, float64(width-paddingLeft-int(stampWidth)-250), float64(height-barHeight+(barHeight-int(stampHeight))/2)+3, 0, 0, float64(240), 1.5, gg.AlignRight)
+func drawPollBars(ctx context.Context, img *gg.Context, poll *PollMetadata, fontSize int, x, y, width, rowHeight int) {
+	for i, option := range poll.Options {
+		if i >= 4 {
+			break
+		}
+		rowY := y + i*rowHeight
+		percent := poll.percent(option)
 
-	return img.Image(), nil
+		// the bar background and then the filled part proportional to the votes
+		img.SetColor(BAR_BACKGROUND)
+		img.DrawRoundedRectangle(float64(x), float64(rowY), float64(width), float64(rowHeight-6), 6)
+		img.Fill()
+		if percent > 0 {
+			img.SetColor(STRONGPINK)
+			img.DrawRoundedRectangle(float64(x), float64(rowY), float64(width*percent/100), float64(rowHeight-6), 6)
+			img.Fill()
+		}
+
+		label := fmt.Sprintf("%d%%  %s", percent, option.Label)
+		textImg, _ := drawParagraphs(ctx, []string{label}, fontSize*3/4, width-20, rowHeight)
+		img.DrawImage(textImg, x+10, rowY+(rowHeight-6-fontSize)/2)
+	}
 }
 
 func drawParagraphs(ctx context.Context, paragraphs []string, fontSize int, width, height int) (image.Image, bool) {
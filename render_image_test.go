@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadingTime(t *testing.T) {
+	for _, tc := range []struct {
+		words    int
+		expected int
+	}{
+		{0, 1},
+		{1, 1},
+		{200, 1},
+		{201, 2},
+		{1000, 5},
+		{1001, 6},
+	} {
+		content := strings.TrimSpace(strings.Repeat("word\n ", tc.words))
+		assert.Equal(t, tc.expected, readingTime(content), "%d words", tc.words)
+	}
+}
@@ -6,14 +6,68 @@ import (
 	"html"
 	"html/template"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"fiatjaf.com/nostr/nip05"
+	"fiatjaf.com/nostr/nip19"
 	"fiatjaf.com/nostr/sdk"
 )
 
+// renderSubPath handles paths like /<code>/<section>, which we can't register as a pattern
+// because they would conflict with the fixed prefixes like /r/ and /e/
+func renderSubPath(w http.ResponseWriter, r *http.Request) {
+	code, section, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if !ok || code == "" || section == "" || strings.Contains(section, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	r.SetPathValue("code", code)
+
+	if section == "history" && strings.HasPrefix(code, "naddr1") {
+		renderHistory(w, r)
+		return
+	}
+
+	r.SetPathValue("tab", section)
+	renderProfileTab(w, r)
+}
+
+func renderProfileTab(w http.ResponseWriter, r *http.Request) {
+	code := r.PathValue("code")
+	if prefix, _, err := nip19.Decode(code); err == nil && prefix != "npub" && prefix != "nprofile" {
+		http.NotFound(w, r)
+		return
+	} else if err != nil && !nip05.IsValidIdentifier(code) {
+		http.NotFound(w, r)
+		return
+	}
+
+	renderProfile(r.Context(), r, w, code)
+}
+
 func renderProfile(ctx context.Context, r *http.Request, w http.ResponseWriter, code string) {
 	isEmbed := r.URL.Query().Get("embed") != ""
+	widget := r.URL.Query().Get("widget")
+
+	// tabs can be selected with a sub-path (in which case the .xml/.rss/.json suffixes go after it) or a query parameter
+	tabId := r.PathValue("tab")
+	for _, suffix := range []string{".xml", ".rss", ".json"} {
+		if strings.HasSuffix(tabId, suffix) {
+			tabId = tabId[:len(tabId)-len(suffix)]
+			code += suffix
+		}
+	}
+	if tabId == "" {
+		tabId = r.URL.Query().Get("tab")
+	}
+	tab, ok := getProfileTab(tabId)
+	if !ok {
+		w.Header().Set("Cache-Control", "public, immutable, s-maxage=86400, max-age=86400")
+		http.Error(w, "unknown profile tab '"+tabId+"'", http.StatusNotFound)
+		return
+	}
 
 	isSitemap := false
 	if strings.HasSuffix(code, ".xml") {
@@ -27,6 +81,21 @@ func renderProfile(ctx context.Context, r *http.Request, w http.ResponseWriter,
 		isRSS = true
 	}
 
+	isJSON := false
+	if strings.HasSuffix(code, ".json") {
+		code = code[:len(code)-5]
+		isJSON = true
+	}
+
+	// feeds can also select the tab by its kinds, like ?kinds=30023 for articles
+	if isRSS || isJSON {
+		if kinds := feedKinds(r); len(kinds) > 0 {
+			if kindsTab, ok := getProfileTabByKinds(kinds); ok {
+				tab = kindsTab
+			}
+		}
+	}
+
 	pp := sdk.InputToProfile(ctx, code)
 	if pp == nil {
 		log.Warn().Str("code", code).Msg("invalid profile code")
@@ -57,31 +126,33 @@ func renderProfile(ctx context.Context, r *http.Request, w http.ResponseWriter,
 		go sys.FetchProfileMetadata(context.Background(), pp.PublicKey)
 	}
 
-	if isMaliciousBridged(profile) {
-		deleteAllEventsFromPubKey(pp.PublicKey)
-		w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
-		log.Warn().Str("pubkey", pp.PublicKey.Hex()).Msg("pubkey malicious bridged blocked")
-		http.Error(w, "profile is malicious", http.StatusNotFound)
-		return
-	}
-	if is, _ := isExplicitContent(ctx, profile.Picture); is {
-		deleteAllEventsFromPubKey(pp.PublicKey)
+	if reason := profileBlockedReason(ctx, profile); reason != "" {
 		w.Header().Set("Cache-Control", "public, immutable, s-maxage=604800, max-age=604800")
-		log.Warn().Str("pubkey", pp.PublicKey.Hex()).Msg("pubkey explicit content blocked")
-		http.Error(w, "profile is not allowed", http.StatusNotFound)
+		http.Error(w, reason, http.StatusNotFound)
 		return
 	}
 
 	var createdAt string
 	if profile.Event != nil {
 		createdAt = profile.Event.CreatedAt.Time().Format("2006-01-02T15:04:05Z07:00")
-		w.Header().Set("ETag", profile.Event.ID.Hex())
 	}
 
+	until := parseUntil(r)
 	var lastNotes []EnhancedEvent
 	var justFetched bool
-	if !isEmbed {
-		lastNotes, justFetched = authorLastNotes(ctx, profile.PubKey)
+	if !isEmbed || widget == "timeline" {
+		lastNotes, justFetched = authorLastEvents(ctx, profile.PubKey, tab, until)
+		lastNotes = skipSeenEvents(r, lastNotes)
+	}
+	nextPage := nextPageURL(r, r.URL.Path, lastNotes, tab.Limit)
+
+	if profile.Event != nil {
+		// each tab and page is a different listing, and it changes whenever a newer event shows up in it
+		etag := profile.Event.ID.Hex() + "-" + tab.ID + "-" + strconv.FormatInt(int64(until), 10)
+		if len(lastNotes) > 0 {
+			etag += "-" + lastNotes[0].ID.Hex()
+		}
+		w.Header().Set("ETag", etag)
 	}
 
 	// Use short cache if notes were just fetched or profile metadata is missing
@@ -102,6 +173,7 @@ func renderProfile(ctx context.Context, r *http.Request, w http.ResponseWriter,
 			ModifiedAt: createdAt,
 			Metadata:   profile,
 			LastNotes:  lastNotes,
+			TabPath:    tab.Path(),
 		})
 		if err == nil {
 			w.Write(buf.Bytes())
@@ -114,19 +186,47 @@ func renderProfile(ctx context.Context, r *http.Request, w http.ResponseWriter,
 		err = RSSTemplate.Render(&buf, &RSSPage{
 			Host:       s.Domain,
 			ModifiedAt: createdAt,
+			Title:      strings.ToLower(tab.Title),
 			Metadata:   profile,
 			LastNotes:  lastNotes,
+			TabPath:    tab.Path(),
+			NextPage:   nextPageURL(r, "https://"+s.Domain+r.URL.Path, lastNotes, tab.Limit),
 		})
 		if err == nil {
 			w.Write(buf.Bytes())
 		}
+	} else if isJSON {
+		author := JSONFeedAuthor{
+			Name:   profile.ShortName(),
+			URL:    "https://" + s.Domain + "/" + profile.Npub(),
+			Avatar: profile.Picture,
+		}
+		err = renderJSONFeed(w, JSONFeed{
+			Title:       "Nostr " + strings.ToLower(tab.Title) + " by " + profile.ShortName(),
+			HomePageURL: "https://" + s.Domain + "/" + profile.Npub() + tab.Path(),
+			FeedURL:     "https://" + s.Domain + r.URL.RequestURI(),
+			Description: profile.About,
+			Icon:        profile.Picture,
+			NextURL:     nextPageURL(r, "https://"+s.Domain+r.URL.Path, lastNotes, tab.Limit),
+			Authors:     []JSONFeedAuthor{author},
+			Items:       jsonFeedItems(lastNotes),
+		})
 	} else {
 		w.Header().Add("content-type", "text/html")
 
 		nprofile := profile.Nprofile(ctx, sys, 2)
+
+		var badges []BadgeDefinition
+		if !isEmbed {
+			badges = authorProfileBadges(ctx, profile.PubKey)
+		}
+
+		oembed := oembedURL(requestHost(r), "/"+profile.Npub())
+		addOEmbedLinkHeaders(w, oembed)
+
 		originalPath := strings.Split(strings.Split(r.URL.Path, "?")[0], "#")[0]
 		params := ProfilePageParams{
-			HeadParams: HeadParams{IsProfile: true},
+			HeadParams: HeadParams{IsProfile: true, Oembed: oembed},
 			Details: DetailsParams{
 				HideDetails:     true,
 				CreatedAt:       createdAt,
@@ -137,6 +237,7 @@ func renderProfile(ctx context.Context, r *http.Request, w http.ResponseWriter,
 				Metadata:        profile,
 			},
 			Metadata:                   profile,
+			CardImage:                  "https://" + requestHost(r) + "/image/" + profile.Npub() + ".png",
 			NormalizedAuthorWebsiteURL: normalizeWebsiteURL(profile.Website),
 			RenderedAuthorAboutText:    template.HTML(basicFormatting(html.EscapeString(profile.About), false, false, false)),
 			Nprofile:                   nprofile,
@@ -144,6 +245,10 @@ func renderProfile(ctx context.Context, r *http.Request, w http.ResponseWriter,
 			AuthorRelays:               relaysPretty(ctx, profile.PubKey),
 			LastNotes:                  lastNotes,
 			FetchingNotes:              len(lastNotes) == 0 && justFetched,
+			NextPage:                   nextPage,
+			Tab:                        tab,
+			Tabs:                       profileTabs,
+			Badges:                     badges,
 			Clients: generateClientList(0, nprofile,
 				func(c ClientReference, s string) string {
 					if c.ID == "nostrudel" {
@@ -164,7 +269,29 @@ func renderProfile(ctx context.Context, r *http.Request, w http.ResponseWriter,
 		defer cancel()
 
 		if isEmbed {
-			err = embeddedProfileTemplate(params).Render(ctx, w)
+			switch widget {
+			case "timeline":
+				subtitle := profile.NIP05
+				if subtitle == "" {
+					subtitle = profile.NpubShort()
+				}
+				// these are all from this profile, so they don't have to load their authors
+				notes := lastNotes[0:min(len(lastNotes), embedLimit(r))]
+				for i := range notes {
+					notes[i].author = profile
+				}
+				err = embeddedTimelineTemplate(EmbeddedTimelineParams{
+					Title:    profile.ShortName(),
+					Subtitle: subtitle,
+					Picture:  profile.Picture,
+					Path:     "/" + profile.Npub() + tab.Path(),
+					Notes:    notes,
+				}).Render(ctx, w)
+			case "card":
+				err = embeddedProfileCardTemplate(params).Render(ctx, w)
+			default:
+				err = embeddedProfileTemplate(params).Render(ctx, w)
+			}
 		} else {
 			err = profileTemplate(params).Render(ctx, w)
 		}
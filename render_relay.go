@@ -6,12 +6,14 @@ import (
 	"strings"
 	"time"
 
+	"fiatjaf.com/nostr"
 	"fiatjaf.com/nostr/nip11"
 )
 
 func renderRelayPage(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	hostname := r.URL.Path[3:]
+	isEmbed := r.URL.Query().Get("embed") != ""
 
 	if strings.HasPrefix(hostname, "wss:/") || strings.HasPrefix(hostname, "ws:/") {
 		hostname = trimProtocolAndEndingSlash(hostname)
@@ -31,6 +33,18 @@ func renderRelayPage(w http.ResponseWriter, r *http.Request) {
 		isRSS = true
 	}
 
+	isJSON := false
+	if strings.HasSuffix(hostname, ".json") {
+		hostname = hostname[:len(hostname)-5]
+		isJSON = true
+	}
+
+	// feeds can be restricted to other kinds, like ?kinds=30023 for articles
+	var kinds []nostr.Kind
+	if isRSS || isJSON {
+		kinds = feedKinds(r)
+	}
+
 	if len(hostname) < 3 {
 		http.Redirect(w, r, "/", http.StatusFound)
 		return
@@ -41,9 +55,10 @@ func renderRelayPage(w http.ResponseWriter, r *http.Request) {
 	if isSitemap {
 		limit = 500
 	}
+	until := parseUntil(r)
 	renderableLastNotes := make([]EnhancedEvent, 0, limit)
 	var lastEventAt *time.Time
-	for evt := range relayLastNotes(ctx, hostname, limit) {
+	for evt := range relayLastNotes(ctx, hostname, kinds, limit, until) {
 		ee := NewEnhancedEvent(ctx, evt)
 		ee.relays = []string{"wss://" + hostname}
 		renderableLastNotes = append(renderableLastNotes, ee)
@@ -52,6 +67,7 @@ func renderRelayPage(w http.ResponseWriter, r *http.Request) {
 			lastEventAt = &last
 		}
 	}
+	renderableLastNotes = skipSeenEvents(r, renderableLastNotes)
 	if lastEventAt == nil {
 		now := time.Now()
 		lastEventAt = &now
@@ -94,24 +110,51 @@ func renderRelayPage(w http.ResponseWriter, r *http.Request) {
 		err = RSSTemplate.Render(&buf, &RSSPage{
 			Host:          s.Domain,
 			ModifiedAt:    lastEventAt.Format("2006-01-02T15:04:05Z07:00"),
+			Title:         feedTitle(kinds),
 			LastNotes:     renderableLastNotes,
 			RelayHostname: hostname,
 			Info:          info,
+			NextPage:      nextPageURL(r, "https://"+s.Domain+r.URL.Path, renderableLastNotes, limit),
 		})
 		if err == nil {
 			w.Write(buf.Bytes())
 		}
 
+	} else if isJSON {
+		title := feedTitle(kinds)
+		if title == "" {
+			title = "notes"
+		}
+		err = renderJSONFeed(w, JSONFeed{
+			Title:       "Nostr " + title + " on " + hostname,
+			HomePageURL: "https://" + s.Domain + "/r/" + hostname,
+			FeedURL:     "https://" + s.Domain + r.URL.RequestURI(),
+			Description: info.Description,
+			Icon:        info.Icon,
+			NextURL:     nextPageURL(r, "https://"+s.Domain+r.URL.Path, renderableLastNotes, limit),
+			Items:       jsonFeedItems(renderableLastNotes),
+		})
+
 	} else {
-		err = relayTemplate(RelayPageParams{
-			HeadParams: HeadParams{IsProfile: false},
+		oembed := oembedURL(requestHost(r), "/r/"+hostname)
+		addOEmbedLinkHeaders(w, oembed)
+
+		params := RelayPageParams{
+			HeadParams: HeadParams{IsProfile: false, Oembed: oembed},
 			Info:       info,
 			Hostname:   hostname,
 			Proxy:      "https://" + hostname + "/proxy?src=",
 			LastNotes:  renderableLastNotes,
+			NextPage:   nextPageURL(r, r.URL.Path, renderableLastNotes, limit),
 			ModifiedAt: lastEventAt.Format("2006-01-02T15:04:05Z07:00"),
 			Clients:    generateClientList(-1, hostname),
-		}).Render(ctx, w)
+		}
+		if isEmbed {
+			params.LastNotes = params.LastNotes[0:min(len(params.LastNotes), 5)]
+			err = embeddedRelayTemplate(params).Render(ctx, w)
+		} else {
+			err = relayTemplate(params).Render(ctx, w)
+		}
 	}
 
 	if err != nil {
@@ -31,5 +31,7 @@ Disallow: /
 
 User-agent: *
 Allow: /
-`)
+
+Sitemap: https://%s/sitemap-index.xml
+`, s.Domain)
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip05"
+	"fiatjaf.com/nostr/nip19"
+	"fiatjaf.com/nostr/sdk"
+)
+
+func renderSearch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	// if we were given something we can display directly just go there
+	code := strings.TrimPrefix(query, "nostr:")
+	if _, _, err := nip19.Decode(code); err == nil {
+		http.Redirect(w, r, "/"+code, http.StatusFound)
+		return
+	}
+	if _, err := nostr.IDFromHex(code); err == nil {
+		http.Redirect(w, r, "/"+code, http.StatusFound)
+		return
+	}
+	if nip05.IsValidIdentifier(code) && strings.Contains(code, ".") {
+		// but things like "bitcoin.org" also look like NIP-05 addresses, so only go if they resolve
+		resolveCtx, cancel := context.WithTimeout(ctx, time.Second*3)
+		pp := sdk.InputToProfile(resolveCtx, code)
+		cancel()
+		if pp != nil {
+			http.Redirect(w, r, "/"+code, http.StatusFound)
+			return
+		}
+	}
+
+	var results SearchResults
+	if query != "" {
+		results = search(ctx, query)
+	}
+
+	w.Header().Set("Cache-Control", "public, s-maxage=300, max-age=300")
+	err := searchTemplate(SearchPageParams{
+		HeadParams: HeadParams{IsProfile: false},
+		Query:      query,
+		Results:    results,
+	}).Render(ctx, w)
+	if err != nil {
+		log.Warn().Err(err).Msg("error rendering tmpl")
+	}
+}
+
+func renderOpenSearch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, s-maxage=86400, max-age=86400")
+	w.Header().Set("Content-Type", "application/opensearchdescription+xml")
+
+	var buf bytes.Buffer
+	buf.WriteString(XML_HEADER)
+	err := OpenSearchTemplate.Render(&buf, &OpenSearchPage{
+		Host: s.Domain,
+	})
+	if err != nil {
+		log.Warn().Err(err).Msg("error rendering tmpl")
+		http.Error(w, "failed to render opensearch description", 500)
+		return
+	}
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func renderSitemapIndex(w http.ResponseWriter, r *http.Request) {
+	npubs := loadSitemapArchive(&sitemapNpubs)
+	events := loadSitemapArchive(&sitemapEvents)
+
+	sitemaps := make([]SitemapIndexEntry, 0, sitemapPages(len(npubs.codes))+sitemapPages(len(events.codes)))
+	for i := range sitemapPages(len(npubs.codes)) {
+		sitemaps = append(sitemaps, SitemapIndexEntry{
+			Path:       fmt.Sprintf("sitemap/npubs/%d.xml", i),
+			ModifiedAt: npubs.updatedAt.Format(time.RFC3339),
+		})
+	}
+	for i := range sitemapPages(len(events.codes)) {
+		sitemaps = append(sitemaps, SitemapIndexEntry{
+			Path:       fmt.Sprintf("sitemap/events/%d.xml", i),
+			ModifiedAt: events.updatedAt.Format(time.RFC3339),
+		})
+	}
+
+	w.Header().Add("content-type", "text/xml")
+	w.Header().Set("Cache-Control", "public, max-age=3600, s-maxage=3600")
+
+	var buf bytes.Buffer
+	buf.WriteString(XML_HEADER)
+	if err := SitemapIndexTemplate.Render(&buf, &SitemapIndexPage{
+		Host:     s.Domain,
+		Sitemaps: sitemaps,
+	}); err != nil {
+		log.Error().Err(err).Msg("error rendering sitemap index")
+		http.Error(w, "failed to render sitemap index", http.StatusInternalServerError)
+		return
+	}
+	w.Write(buf.Bytes())
+}
+
+func renderArchiveSitemap(w http.ResponseWriter, r *http.Request) {
+	pageStr, ok := strings.CutSuffix(r.PathValue("page"), ".xml")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	page, err := strconv.Atoi(pageStr)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var archive *sitemapArchive
+	switch r.PathValue("archive") {
+	case "npubs":
+		archive = loadSitemapArchive(&sitemapNpubs)
+	case "events":
+		archive = loadSitemapArchive(&sitemapEvents)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	items := sitemapPage(archive.codes, page)
+	if len(items) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Add("content-type", "text/xml")
+	w.Header().Set("Cache-Control", "public, max-age=3600, s-maxage=3600")
+
+	var buf bytes.Buffer
+	buf.WriteString(XML_HEADER)
+	if err := SitemapTemplate.Render(&buf, &SitemapPage{
+		Host:       s.Domain,
+		ModifiedAt: archive.updatedAt.Format(time.RFC3339),
+		Data:       items,
+	}); err != nil {
+		log.Error().Err(err).Msg("error rendering archive sitemap")
+		http.Error(w, "failed to render sitemap", http.StatusInternalServerError)
+		return
+	}
+	w.Write(buf.Bytes())
+}
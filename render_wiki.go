@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+)
+
+func renderWikiTopic(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	topic := r.PathValue("topic")
+
+	normalized := normalizeDTag(topic)
+	if normalized == "" {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	if normalized != topic {
+		http.Redirect(w, r, "/w/"+url.PathEscape(normalized), http.StatusFound)
+		return
+	}
+
+	articles := wikiTopicArticles(ctx, topic)
+	if len(articles) == 0 {
+		w.Header().Set("Cache-Control", "public, s-maxage=600, max-age=600")
+		w.WriteHeader(http.StatusNotFound)
+	} else {
+		w.Header().Set("Cache-Control", "public, s-maxage=3600, max-age=3600")
+	}
+
+	err := wikiTopicTemplate(WikiTopicPageParams{
+		HeadParams: HeadParams{IsProfile: false},
+		Topic:      topic,
+		Articles:   articles,
+	}).Render(ctx, w)
+	if err != nil {
+		log.Warn().Err(err).Msg("error rendering tmpl")
+	}
+}
@@ -11,22 +11,24 @@ var npubsArchive map[nostr.PubKey]struct{}
 
 func updateArchives(ctx context.Context) {
 	for {
+		log.Debug().Msg("refreshing the npubs archive")
+
+		pubkeySet := make(map[nostr.PubKey]struct{})
+		for _, pubkey := range s.trustedPubKeys {
+			ctx, cancel := context.WithTimeout(ctx, time.Second*4)
+			follows := sys.FetchFollowList(ctx, pubkey)
+			for _, follow := range follows.Items {
+				pubkeySet[follow.Pubkey] = struct{}{}
+			}
+			cancel()
+		}
+		npubsArchive = pubkeySet
+		sitemapNpubs.Store(&sitemapArchive{codes: buildSitemapNpubs(pubkeySet), updatedAt: time.Now()})
+
 		select {
 		case <-ctx.Done():
 			return
 		case <-time.After(24 * time.Hour * 3):
-			log.Debug().Msg("refreshing the npubs archive")
-
-			pubkeySet := make(map[nostr.PubKey]struct{})
-			for _, pubkey := range s.trustedPubKeys {
-				ctx, cancel := context.WithTimeout(ctx, time.Second*4)
-				follows := sys.FetchFollowList(ctx, pubkey)
-				for _, follow := range follows.Items {
-					pubkeySet[follow.Pubkey] = struct{}{}
-				}
-				cancel()
-			}
-			npubsArchive = pubkeySet
 		}
 	}
 }
@@ -48,6 +50,27 @@ func deleteOldCachedEvents(ctx context.Context, cacheRetentionDays int) {
 					deleteEvent(id)
 				}
 			}
+
+			// and the old versions of addressable events whose latest version is gone or
+			// that we don't keep the history of anymore
+			for evt := range historyStore.QueryEvents(nostr.Filter{Until: threshold}, 999999) {
+				if !hasHistory(evt.Kind) || isRevisionOrphaned(evt) {
+					historyStore.DeleteEvent(evt.ID)
+				}
+			}
+		}
+	}
+}
+
+func updateSearchIndex(ctx context.Context) {
+	for {
+		log.Debug().Msg("rebuilding the local search index")
+		searchIndex.rebuild()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Hour):
 		}
 	}
 }
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/sdk"
+)
+
+const (
+	SEARCH_INDEX_MAX_EVENTS = 50000
+	SEARCH_MAX_RESULTS      = 20
+)
+
+var (
+	searchProfileKinds = []nostr.Kind{0}
+	searchNoteKinds    = []nostr.Kind{1}
+	searchArticleKinds = []nostr.Kind{30023}
+)
+
+// localSearchIndex is a simple inverted index from words to the ids of the events in our local store
+// that contain them. it is rebuilt periodically so events deleted from the store eventually go away.
+type localSearchIndex struct {
+	sync.RWMutex
+	words map[string][]nostr.ID
+}
+
+var searchIndex = &localSearchIndex{words: make(map[string][]nostr.ID)}
+
+// tokenize splits a text into unique lowercase words, ignoring very short ones
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	unique := make([]string, 0, len(words))
+	for _, word := range words {
+		if len([]rune(word)) < 3 || len(word) > 64 {
+			continue
+		}
+		if !slices.Contains(unique, word) {
+			unique = append(unique, word)
+		}
+		if len(unique) >= 200 {
+			break
+		}
+	}
+	return unique
+}
+
+// searchableText is what we index for each kind
+func searchableText(evt nostr.Event) string {
+	switch evt.Kind {
+	case 0:
+		pm, _ := sdk.ParseMetadata(evt)
+		return strings.Join([]string{pm.Name, pm.DisplayName, pm.NIP05, pm.About}, " ")
+	case 30023:
+		ee := NewEnhancedEventWithoutMetadata(evt)
+		return ee.subject + " " + ee.summary + " " + evt.Content
+	default:
+		return evt.Content
+	}
+}
+
+func (idx *localSearchIndex) rebuild() {
+	words := make(map[string][]nostr.ID, len(idx.words))
+
+	kinds := slices.Concat(searchProfileKinds, searchNoteKinds, searchArticleKinds)
+	for evt := range sys.Store.QueryEvents(nostr.Filter{Kinds: kinds}, SEARCH_INDEX_MAX_EVENTS) {
+		for _, word := range tokenize(searchableText(evt)) {
+			words[word] = append(words[word], evt.ID)
+		}
+	}
+
+	idx.Lock()
+	idx.words = words
+	idx.Unlock()
+}
+
+// matching returns the ids of the events that contain all the given words
+func (idx *localSearchIndex) matching(terms []string) []nostr.ID {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	idx.RLock()
+	lists := make([][]nostr.ID, len(terms))
+	for i, term := range terms {
+		lists[i] = idx.words[term]
+	}
+	idx.RUnlock()
+
+	// intersect starting from the rarest words so the sets we build are as small as possible
+	slices.SortFunc(lists, func(a, b []nostr.ID) int { return cmp.Compare(len(a), len(b)) })
+	candidates := slices.Clone(lists[0])
+	for _, ids := range lists[1:] {
+		if len(candidates) == 0 {
+			break
+		}
+		set := make(map[nostr.ID]struct{}, len(candidates))
+		for _, id := range candidates {
+			set[id] = struct{}{}
+		}
+		candidates = candidates[:0]
+		for _, id := range ids {
+			if _, ok := set[id]; ok {
+				candidates = append(candidates, id)
+			}
+		}
+	}
+	return candidates
+}
+
+// search returns the events in the local store that contain all the words in the query
+func (idx *localSearchIndex) search(query string, kinds []nostr.Kind, limit int) []nostr.Event {
+	candidates := idx.matching(tokenize(query))
+	if len(candidates) == 0 {
+		return nil
+	}
+	if len(candidates) > DB_MAX_LIMIT {
+		candidates = candidates[0:DB_MAX_LIMIT]
+	}
+
+	results := make([]nostr.Event, 0, limit)
+	for evt := range sys.Store.QueryEvents(nostr.Filter{IDs: candidates, Kinds: kinds}, DB_MAX_LIMIT) {
+		results = append(results, evt)
+		if len(results) >= limit {
+			break
+		}
+	}
+	return results
+}
+
+type SearchResults struct {
+	Profiles []sdk.ProfileMetadata
+	Notes    []EnhancedEvent
+	Articles []EnhancedEvent
+}
+
+func (sr SearchResults) isEmpty() bool {
+	return len(sr.Profiles) == 0 && len(sr.Notes) == 0 && len(sr.Articles) == 0
+}
+
+func isSearchResultHidden(evt *nostr.Event) bool {
+	if banned, _ := isEventBanned(evt.ID); banned {
+		return true
+	}
+	if banned, _ := isPubkeyBanned(evt.PubKey); banned {
+		return true
+	}
+	return hasProhibitedWordOrTag(evt)
+}
+
+// searchEvents runs a NIP-50 search on the configured search relays and a search on our local
+// index at the same time, merging the results for the given kinds
+func searchEvents(ctx context.Context, query string, kinds []nostr.Kind) []nostr.Event {
+	results := make([]nostr.Event, 0, SEARCH_MAX_RESULTS)
+	seen := make(map[nostr.ID]struct{}, SEARCH_MAX_RESULTS)
+
+	if len(relayConfig.Search) > 0 {
+		ctx, cancel := context.WithTimeout(ctx, time.Second*4)
+		for ie := range sys.Pool.FetchMany(ctx, relayConfig.Search, nostr.Filter{
+			Kinds:  kinds,
+			Search: query,
+			Limit:  SEARCH_MAX_RESULTS,
+		}, nostr.SubscriptionOptions{Label: "search"}) {
+			if _, ok := seen[ie.Event.ID]; ok {
+				continue
+			}
+			seen[ie.Event.ID] = struct{}{}
+			sys.Store.SaveEvent(ie.Event)
+			results = append(results, ie.Event)
+		}
+		cancel()
+	}
+
+	for _, evt := range searchIndex.search(query, kinds, SEARCH_MAX_RESULTS) {
+		if _, ok := seen[evt.ID]; ok {
+			continue
+		}
+		seen[evt.ID] = struct{}{}
+		results = append(results, evt)
+	}
+
+	results = slices.DeleteFunc(results, func(evt nostr.Event) bool { return isSearchResultHidden(&evt) })
+	if len(results) > SEARCH_MAX_RESULTS {
+		results = results[0:SEARCH_MAX_RESULTS]
+	}
+	return results
+}
+
+func search(ctx context.Context, query string) SearchResults {
+	var sr SearchResults
+	wg := sync.WaitGroup{}
+	wg.Add(3)
+
+	go func() {
+		defer wg.Done()
+		pubkeys := make([]nostr.PubKey, 0, SEARCH_MAX_RESULTS)
+		for _, evt := range searchEvents(ctx, query, searchProfileKinds) {
+			if slices.Contains(pubkeys, evt.PubKey) {
+				continue
+			}
+			pm, err := sdk.ParseMetadata(evt)
+			if err != nil || isMaliciousBridged(pm) {
+				continue
+			}
+			pubkeys = append(pubkeys, evt.PubKey)
+			sr.Profiles = append(sr.Profiles, pm)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for _, evt := range searchEvents(ctx, query, searchNoteKinds) {
+			sr.Notes = append(sr.Notes, NewEnhancedEvent(ctx, evt))
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for _, evt := range searchEvents(ctx, query, searchArticleKinds) {
+			sr.Articles = append(sr.Articles, NewEnhancedEvent(ctx, evt))
+		}
+	}()
+
+	wg.Wait()
+	return sr
+}
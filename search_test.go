@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"hello", "nostr", "world", "you"}, tokenize("Hello, NOSTR world! hello to you"))
+	assert.Equal(t, []string{"café", "über"}, tokenize("café über ok"))
+	assert.Empty(t, tokenize("a b c"))
+}
+
+func TestLocalSearchIndexMatching(t *testing.T) {
+	id := func(c byte) nostr.ID {
+		var id nostr.ID
+		id[0] = c
+		return id
+	}
+
+	idx := &localSearchIndex{words: map[string][]nostr.ID{
+		"nostr":   {id(1), id(2), id(3), id(4)},
+		"relay":   {id(2), id(4), id(5)},
+		"outbox":  {id(4), id(2)},
+		"bitcoin": {id(6)},
+	}}
+
+	for _, tc := range []struct {
+		terms    []string
+		expected []nostr.ID
+	}{
+		{[]string{"nostr"}, []nostr.ID{id(1), id(2), id(3), id(4)}},
+		{[]string{"nostr", "relay"}, []nostr.ID{id(2), id(4)}},
+		{[]string{"relay", "nostr", "outbox"}, []nostr.ID{id(2), id(4)}},
+		{[]string{"nostr", "bitcoin"}, []nostr.ID{}},
+		{[]string{"nostr", "missing"}, []nostr.ID{}},
+		{nil, nil},
+	} {
+		assert.ElementsMatch(t, tc.expected, idx.matching(tc.terms), "%v", tc.terms)
+	}
+
+	// the index itself must not be modified
+	assert.Equal(t, []nostr.ID{id(1), id(2), id(3), id(4)}, idx.words["nostr"])
+}
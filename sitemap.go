@@ -0,0 +1,120 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+const (
+	SITEMAP_PAGE_SIZE         = 5000
+	SITEMAP_MAX_EVENTS        = 100000
+	SITEMAP_EVENTS_ACCESS_AGE = time.Hour * 24 * 14
+)
+
+// sitemapEventKinds are the kinds we render full pages for, everything else isn't worth indexing
+var sitemapEventKinds = []nostr.Kind{1, 20, 21, 22, 1063, 1111, 9802, 30023, 30311, 30818, 31922, 31923, 31924}
+
+// sitemapArchive is a snapshot of the codes listed in one of the archive sitemaps. the archive
+// routines build a new one and swap it in whole, so handlers always see a consistent list.
+type sitemapArchive struct {
+	codes     []string
+	updatedAt time.Time
+}
+
+var sitemapNpubs, sitemapEvents atomic.Pointer[sitemapArchive]
+
+// loadSitemapArchive returns the current snapshot, which is empty before the first refresh
+func loadSitemapArchive(archive *atomic.Pointer[sitemapArchive]) *sitemapArchive {
+	if current := archive.Load(); current != nil {
+		return current
+	}
+	return &sitemapArchive{}
+}
+
+// buildSitemapNpubs turns the npubs archive into a sorted list of npubs that are not banned
+func buildSitemapNpubs(archive map[nostr.PubKey]struct{}) []string {
+	npubs := make([]string, 0, len(archive))
+	for pk := range archive {
+		if banned, _ := isPubkeyBanned(pk); banned {
+			continue
+		}
+		npubs = append(npubs, nip19.EncodeNpub(pk))
+	}
+	slices.Sort(npubs)
+	return npubs
+}
+
+// updateEventsArchive lists the events in our cache that people have been looking at recently,
+// most recently accessed first, so they can be listed in the sitemaps
+func updateEventsArchive(ctx context.Context) {
+	for {
+		log.Debug().Msg("refreshing the events archive")
+
+		type accessed struct {
+			code string
+			at   nostr.Timestamp
+		}
+
+		threshold := nostr.Now() - nostr.Timestamp(SITEMAP_EVENTS_ACCESS_AGE.Seconds())
+		events := make([]accessed, 0, 1000)
+		for evt := range sys.Store.QueryEvents(nostr.Filter{Kinds: sitemapEventKinds}, 999999) {
+			accessTime := sys.GetEventAccessTime(evt.ID)
+			if accessTime < threshold {
+				continue
+			}
+			if banned, _ := isEventBanned(evt.ID); banned {
+				continue
+			}
+			if banned, _ := isPubkeyBanned(evt.PubKey); banned {
+				continue
+			}
+			if hasProhibitedWordOrTag(&evt) {
+				continue
+			}
+			events = append(events, accessed{NewEnhancedEventWithoutMetadata(evt).Code(), accessTime})
+		}
+
+		slices.SortFunc(events, func(a, b accessed) int {
+			if a.at != b.at {
+				return cmp.Compare(b.at, a.at)
+			}
+			return strings.Compare(a.code, b.code)
+		})
+		if len(events) > SITEMAP_MAX_EVENTS {
+			events = events[0:SITEMAP_MAX_EVENTS]
+		}
+
+		codes := make([]string, len(events))
+		for i, e := range events {
+			codes[i] = e.code
+		}
+		sitemapEvents.Store(&sitemapArchive{codes: codes, updatedAt: time.Now()})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Hour * 6):
+		}
+	}
+}
+
+// sitemapPages returns how many sitemap pages are needed for a list of the given size
+func sitemapPages(total int) int {
+	return (total + SITEMAP_PAGE_SIZE - 1) / SITEMAP_PAGE_SIZE
+}
+
+// sitemapPage returns the items of the nth (starting from 0) page, or nil if out of range
+func sitemapPage(items []string, n int) []string {
+	start := n * SITEMAP_PAGE_SIZE
+	if n < 0 || start >= len(items) {
+		return nil
+	}
+	return items[start:min(start+SITEMAP_PAGE_SIZE, len(items))]
+}
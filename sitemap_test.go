@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSitemapPages(t *testing.T) {
+	items := make([]string, SITEMAP_PAGE_SIZE*2+10)
+	for i := range items {
+		items[i] = fmt.Sprintf("item%d", i)
+	}
+
+	assert.Equal(t, 0, sitemapPages(0))
+	assert.Equal(t, 1, sitemapPages(1))
+	assert.Equal(t, 1, sitemapPages(SITEMAP_PAGE_SIZE))
+	assert.Equal(t, 3, sitemapPages(len(items)))
+
+	assert.Len(t, sitemapPage(items, 0), SITEMAP_PAGE_SIZE)
+	assert.Equal(t, "item0", sitemapPage(items, 0)[0])
+	assert.Len(t, sitemapPage(items, 2), 10)
+	assert.Equal(t, fmt.Sprintf("item%d", SITEMAP_PAGE_SIZE*2), sitemapPage(items, 2)[0])
+	assert.Nil(t, sitemapPage(items, 3))
+	assert.Nil(t, sitemapPage(items, -1))
+}
+
+func TestRenderArchiveSitemap(t *testing.T) {
+	request := func(archive string, page string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/sitemap/"+archive+"/"+page, nil)
+		r.SetPathValue("archive", archive)
+		r.SetPathValue("page", page)
+		w := httptest.NewRecorder()
+		renderArchiveSitemap(w, r)
+		return w
+	}
+
+	sitemapEvents.Store(nil)
+	assert.Equal(t, http.StatusNotFound, request("events", "0.xml").Code, "before the first refresh")
+
+	sitemapEvents.Store(&sitemapArchive{codes: []string{"nevent1one", "naddr1two"}, updatedAt: time.Now()})
+	w := request("events", "0.xml")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "nevent1one")
+	assert.Contains(t, w.Body.String(), "naddr1two")
+
+	assert.Equal(t, http.StatusNotFound, request("events", "1.xml").Code)
+	assert.Equal(t, http.StatusNotFound, request("events", "0").Code)
+	assert.Equal(t, http.StatusNotFound, request("other", "0.xml").Code)
+}
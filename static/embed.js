@@ -1,23 +1,52 @@
 (function() {
     var scriptElement = document.currentScript;
 
-    // Extract the event ID from the script's src attribute
+    // Extract the host from the script's src attribute
     var scriptSrc = scriptElement.src;
     var host = new URL(scriptSrc).origin;
 
-    // Extract the event parameter from the script's src attribute
-    var eventParam = scriptSrc.substring(scriptSrc.lastIndexOf('/') + 1);
+    // Extract what we're embedding from the script's src attribute, it's a nip19 code
+    // or t/<hashtag> for hashtag timelines
+    var path = new URL(scriptSrc).pathname;
+    var target = path.substring(path.indexOf('/embed/') + 7);
+
+    // Widget modes:
+    // - note (default): a single event, as before
+    // - timeline: the latest notes from a profile or hashtag, use limit="N" to choose how many
+    // - profile: a compact profile card
+    // - thread: a note with its parents and replies
+    var mode = scriptElement.getAttribute('mode') || (target.indexOf('t/') === 0 ? 'timeline' : 'note');
+    var params = new URLSearchParams({embed: 'yes'});
+    switch (mode) {
+        case 'timeline':
+            params.set('widget', 'timeline');
+            if (scriptElement.hasAttribute('limit')) {
+                params.set('limit', scriptElement.getAttribute('limit'));
+            }
+            break;
+        case 'profile':
+            params.set('widget', 'card');
+            break;
+        case 'thread':
+            params.set('widget', 'thread');
+            break;
+    }
+
+    // theme can be light, dark or auto (follows the reader's preference)
+    var theme = scriptElement.getAttribute('theme') || 'auto';
+    var accent = scriptElement.getAttribute('accent');
 
     var width = scriptElement.getAttribute('width') || '100%';
     var height = scriptElement.getAttribute('height') || 'auto';
     var iframe = document.createElement('iframe');
-    iframe.src = host + '/' + eventParam + '?embed=yes';
+    iframe.src = host + '/' + target + '?' + params.toString();
     iframe.style.width = width;
     iframe.style.height = height;
 
     // Add a class to easily permit overwriting the styles
     iframe.classList.add("nostr-embedded")
-    
+    iframe.classList.add("nostr-embedded-" + mode)
+
     const myCSSClass = `
     .nostr-embedded {
         border: 2px solid #C9C9C9;
@@ -35,12 +64,26 @@
     scriptElement.parentNode.insertBefore(styleElement, scriptElement.nextSibling);
     scriptElement.parentNode.insertBefore(iframe, scriptElement.nextSibling);
 
+    if (theme === 'dark') {
+        iframe.style.borderColor = '#393939';
+    } else if (theme === 'light') {
+        iframe.style.borderColor = '#C9C9C9';
+    }
+    if (accent) {
+        iframe.style.borderColor = accent;
+    }
+
     // Listen for messages from the iframe
     window.addEventListener('message', function(event) {
+        // there may be multiple widgets on the page, only handle our own
+        if (event.source !== iframe.contentWindow) {
+            return;
+        }
+
         // Check if the 'height' attribute is explicitly set
         if (!scriptElement.hasAttribute('height')) {
-            // Calculate the maximum height based on 50% of the viewport height
-            var maxViewportHeight = window.innerHeight * 0.5;
+            // Calculate the maximum height based on 50% of the viewport height (more for timelines and threads)
+            var maxViewportHeight = window.innerHeight * (mode === 'timeline' || mode === 'thread' ? 0.8 : 0.5);
 
             // Adjust the height of the iframe based on the received content height
             var receivedHeight = Math.min(event.data.height, maxViewportHeight);
@@ -51,10 +94,14 @@
             }
         }
 
-        // Check if dark mode is preferred
+        // Check if dark mode is forced or preferred
         const darkModeQuery = window.matchMedia('(prefers-color-scheme: dark)');
-        if (darkModeQuery.matches) {
+        if (theme === 'dark' || (theme !== 'light' && darkModeQuery.matches)) {
             iframe.contentWindow.postMessage({setDarkMode: true}, '*');
         }
+
+        if (accent) {
+            iframe.contentWindow.postMessage({setAccent: accent}, '*');
+        }
     });
 })();
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"html"
+	"path"
+	"strings"
+)
+
+// a tiny line-based syntax highlighter for the code inside patches, it only knows about
+// keywords, strings, numbers and comments, which is enough to make diffs readable
+
+type syntaxSpec struct {
+	keywords      map[string]bool
+	lineComments  []string
+	blockComments [][2]string
+	quotes        string
+}
+
+const (
+	syntaxKeywordClass = "text-purple-700 dark:text-purple-400"
+	syntaxStringClass  = "text-amber-700 dark:text-amber-300"
+	syntaxNumberClass  = "text-sky-700 dark:text-sky-300"
+	syntaxCommentClass = "italic text-stone-500 dark:text-neutral-400"
+)
+
+func keywordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+var (
+	cStyleComments = [][2]string{{"/*", "*/"}}
+
+	goSyntax = &syntaxSpec{
+		keywords: keywordSet(`break case chan const continue default defer else fallthrough for func go goto if
+			import interface map package range return select struct switch type var nil true false`),
+		lineComments:  []string{"//"},
+		blockComments: cStyleComments,
+		quotes:        "\"'`",
+	}
+	jsSyntax = &syntaxSpec{
+		keywords: keywordSet(`async await break case catch class const continue debugger default delete do else
+			export extends finally for from function if import in instanceof let new of return static super switch
+			this throw try typeof var void while yield null undefined true false interface type enum implements`),
+		lineComments:  []string{"//"},
+		blockComments: cStyleComments,
+		quotes:        "\"'`",
+	}
+	rustSyntax = &syntaxSpec{
+		keywords: keywordSet(`as async await break const continue crate dyn else enum extern false fn for if impl in
+			let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while`),
+		lineComments:  []string{"//"},
+		blockComments: cStyleComments,
+		quotes:        "\"",
+	}
+	cSyntax = &syntaxSpec{
+		keywords: keywordSet(`auto break case catch char class const continue default delete do double else enum
+			extern false final float for goto if import int long namespace new null nullptr package private protected
+			public return short signed sizeof static struct switch this throw true try typedef union unsigned using
+			void volatile while fun val var let func guard`),
+		lineComments:  []string{"//"},
+		blockComments: cStyleComments,
+		quotes:        "\"'",
+	}
+	pythonSyntax = &syntaxSpec{
+		keywords: keywordSet(`and as assert async await break class continue def del elif else except False finally
+			for from global if import in is lambda None nonlocal not or pass raise return True try while with yield`),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	}
+	shellSyntax = &syntaxSpec{
+		keywords:     keywordSet(`if then else elif fi case esac for while until do done in function return local export`),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	}
+	nixSyntax = &syntaxSpec{
+		keywords:      keywordSet(`let in with rec inherit if then else assert import true false null`),
+		lineComments:  []string{"#"},
+		blockComments: cStyleComments,
+		quotes:        "\"",
+	}
+
+	syntaxByExtension = map[string]*syntaxSpec{
+		".go":     goSyntax,
+		".js":     jsSyntax,
+		".jsx":    jsSyntax,
+		".mjs":    jsSyntax,
+		".ts":     jsSyntax,
+		".tsx":    jsSyntax,
+		".svelte": jsSyntax,
+		".rs":     rustSyntax,
+		".c":      cSyntax,
+		".h":      cSyntax,
+		".cpp":    cSyntax,
+		".hpp":    cSyntax,
+		".java":   cSyntax,
+		".kt":     cSyntax,
+		".swift":  cSyntax,
+		".cs":     cSyntax,
+		".dart":   cSyntax,
+		".py":     pythonSyntax,
+		".rb":     pythonSyntax,
+		".sh":     shellSyntax,
+		".bash":   shellSyntax,
+		".nix":    nixSyntax,
+	}
+)
+
+// syntaxForDiffHeader picks the syntax from the file name in a "diff --git a/... b/..." line
+func syntaxForDiffHeader(line string) *syntaxSpec {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return nil
+	}
+	return syntaxByExtension[strings.ToLower(path.Ext(fields[len(fields)-1]))]
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func writeSyntaxSpan(sb *strings.Builder, class string, text string) {
+	sb.WriteString(`<span class="` + class + `">`)
+	sb.WriteString(html.EscapeString(text))
+	sb.WriteString(`</span>`)
+}
+
+// highlightCode returns the line as escaped HTML with spans around the tokens we recognize.
+// since we look at one line at a time strings and comments spanning multiple lines aren't detected
+func highlightCode(line string, spec *syntaxSpec) string {
+	if spec == nil {
+		return html.EscapeString(line)
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(line) * 2)
+
+	i := 0
+tokens:
+	for i < len(line) {
+		for _, prefix := range spec.lineComments {
+			if strings.HasPrefix(line[i:], prefix) {
+				writeSyntaxSpan(&sb, syntaxCommentClass, line[i:])
+				break tokens
+			}
+		}
+		for _, delims := range spec.blockComments {
+			if strings.HasPrefix(line[i:], delims[0]) {
+				end := strings.Index(line[i+len(delims[0]):], delims[1])
+				if end == -1 {
+					end = len(line)
+				} else {
+					end = i + len(delims[0]) + end + len(delims[1])
+				}
+				writeSyntaxSpan(&sb, syntaxCommentClass, line[i:end])
+				i = end
+				continue tokens
+			}
+		}
+
+		c := line[i]
+		switch {
+		case strings.IndexByte(spec.quotes, c) != -1:
+			end := i + 1
+			for end < len(line) && line[end] != c {
+				if line[end] == '\\' && c != '`' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(line))
+			writeSyntaxSpan(&sb, syntaxStringClass, line[i:end])
+			i = end
+		case c >= '0' && c <= '9' && (i == 0 || !isIdentifierChar(line[i-1])):
+			end := i + 1
+			for end < len(line) && (isIdentifierChar(line[end]) || line[end] == '.') {
+				end++
+			}
+			writeSyntaxSpan(&sb, syntaxNumberClass, line[i:end])
+			i = end
+		case isIdentifierChar(c):
+			end := i + 1
+			for end < len(line) && isIdentifierChar(line[end]) {
+				end++
+			}
+			if spec.keywords[line[i:end]] {
+				writeSyntaxSpan(&sb, syntaxKeywordClass, line[i:end])
+			} else {
+				sb.WriteString(line[i:end])
+			}
+			i = end
+		default:
+			// everything else goes one byte at a time, multibyte characters are written back
+			// together since none of their bytes match anything above
+			sb.WriteString(html.EscapeString(line[i : i+1]))
+			i++
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"html"
+	"html/template"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"fiatjaf.com/nostr/nip19"
+)
+
+const (
+	THREAD_MAX_ANCESTORS = 12
+	THREAD_MAX_DEPTH     = 5
+	THREAD_MAX_REPLIES   = 150
+	THREAD_PAGE_SIZE     = 20
+)
+
+func isThreadable(kind nostr.Kind) bool {
+	return kind == 1 || kind == 11 || kind == 1111
+}
+
+// moreRepliesLink is the link to the next page of replies, it keeps the rest of the query
+// so the embedded thread widget stays embedded
+func moreRepliesLink(query url.Values, next int) string {
+	query = maps.Clone(query)
+	query.Set("replies", strconv.Itoa(next))
+	return "?" + query.Encode()
+}
+
+func newThreadItem(evt EnhancedEvent, depth int) ThreadItem {
+	return ThreadItem{
+		Event:   evt,
+		Nevent:  nip19.EncodeNevent(evt.ID, nil, evt.PubKey),
+		Content: template.HTML(basicFormatting(html.EscapeString(evt.Content), false, false, false)),
+		Depth:   depth,
+	}
+}
+
+func isThreadEventHidden(evt *nostr.Event) bool {
+	if banned, _ := isEventBanned(evt.ID); banned {
+		return true
+	}
+	if banned, _ := isPubkeyBanned(evt.PubKey); banned {
+		return true
+	}
+	return hasProhibitedWordOrTag(evt)
+}
+
+// fetchThreadAncestors walks up the chain of parents until the root, returning them ordered from the root
+func fetchThreadAncestors(ctx context.Context, event EnhancedEvent) []ThreadItem {
+	ancestors := make([]ThreadItem, 0, 4)
+
+	current := event
+	for len(ancestors) < THREAD_MAX_ANCESTORS {
+		pointer, ok := current.getParent().(nostr.EventPointer)
+		if !ok {
+			// also stops on addressable and external roots of NIP-22 comments
+			break
+		}
+
+		parent, _ := getEvent(ctx, nip19.EncodePointer(pointer), false)
+		if parent == nil || isThreadEventHidden(parent) {
+			break
+		}
+
+		current = NewEnhancedEventWithoutMetadata(*parent)
+		ancestors = append(ancestors, newThreadItem(current, 0))
+		if !isThreadable(parent.Kind) {
+			break
+		}
+	}
+
+	slices.Reverse(ancestors)
+	loadThreadAuthors(ctx, ancestors)
+	return ancestors
+}
+
+// fetchThreadReplies builds the tree of replies below an event using the author's read relays,
+// merged with what we have in the local store. the first level is paginated with offset.
+func fetchThreadReplies(ctx context.Context, event EnhancedEvent, offset int) (replies []ThreadItem, hasMore bool) {
+	relays := sys.FetchInboxRelays(ctx, event.PubKey, 3)
+	for len(relays) < 3 {
+		relays = appendUnique(relays, sys.FallbackRelays.Next())
+	}
+
+	replies, hasMore = threadReplies(event, offset, func(filter nostr.Filter) {
+		fetchCtx, cancel := context.WithTimeout(ctx, time.Second*3)
+		defer cancel()
+		for ie := range sys.Pool.FetchMany(fetchCtx, relays, filter, nostr.SubscriptionOptions{Label: "thread"}) {
+			sys.Store.SaveEvent(ie.Event)
+		}
+	})
+
+	loadThreadAuthors(ctx, replies)
+	return replies, hasMore
+}
+
+// threadReplies builds the tree of replies below an event from the local store, level by level, using
+// NIP-10 for kind 1 and NIP-22 comments for everything else. fetchLevel is called before each level is
+// read so it can bring more replies into the store.
+func threadReplies(event EnhancedEvent, offset int, fetchLevel func(filter nostr.Filter)) (replies []ThreadItem, hasMore bool) {
+	kinds := []nostr.Kind{1111}
+	if event.Kind == 1 {
+		kinds = []nostr.Kind{1}
+	}
+
+	type node struct {
+		item     ThreadItem
+		children []*node
+	}
+	nodes := make(map[nostr.ID]*node, THREAD_MAX_REPLIES)
+	root := &node{}
+	nodes[event.ID] = root
+
+	level := []nostr.ID{event.ID}
+	total := 0
+	for depth := 1; depth <= THREAD_MAX_DEPTH && len(level) > 0 && total < THREAD_MAX_REPLIES; depth++ {
+		ids := make([]string, len(level))
+		for i, id := range level {
+			ids[i] = id.Hex()
+		}
+		filter := nostr.Filter{
+			Kinds: kinds,
+			Tags:  nostr.TagMap{"e": ids},
+		}
+
+		fetchLevel(filter)
+
+		found := make([]nostr.Event, 0, len(level)*4)
+		for evt := range sys.Store.QueryEvents(filter, DB_MAX_LIMIT) {
+			if _, seen := nodes[evt.ID]; seen || isThreadEventHidden(&evt) {
+				continue
+			}
+			found = append(found, evt)
+		}
+		slices.SortFunc(found, func(a, b nostr.Event) int { return cmp.Compare(a.CreatedAt, b.CreatedAt) })
+
+		next := make([]nostr.ID, 0, len(found))
+		for _, evt := range found {
+			if total >= THREAD_MAX_REPLIES {
+				break
+			}
+
+			// kind 1 replies tag the root too, so we must only attach them under their immediate parent
+			ee := NewEnhancedEventWithoutMetadata(evt)
+			pointer, ok := ee.getParent().(nostr.EventPointer)
+			if !ok {
+				continue
+			}
+			parent, ok := nodes[pointer.ID]
+			if !ok || !slices.Contains(level, pointer.ID) {
+				continue
+			}
+
+			child := &node{item: newThreadItem(ee, depth)}
+			parent.children = append(parent.children, child)
+			nodes[evt.ID] = child
+			next = append(next, evt.ID)
+			total++
+		}
+		level = next
+	}
+
+	// only the top-level replies are paginated
+	children := root.children
+	if offset >= len(children) {
+		return nil, false
+	}
+	children = children[offset:]
+	if len(children) > THREAD_PAGE_SIZE {
+		children = children[0:THREAD_PAGE_SIZE]
+		hasMore = true
+	}
+
+	var flatten func(n *node) ThreadItem
+	flatten = func(n *node) ThreadItem {
+		for _, child := range n.children {
+			n.item.Replies = append(n.item.Replies, flatten(child))
+		}
+		return n.item
+	}
+
+	replies = make([]ThreadItem, len(children))
+	for i, child := range children {
+		replies[i] = flatten(child)
+	}
+	return replies, hasMore
+}
+
+// loadThreadAuthors loads the profiles of everybody in the thread at once
+func loadThreadAuthors(ctx context.Context, items []ThreadItem) {
+	pubkeys := make([]nostr.PubKey, 0, len(items))
+	var collect func(items []ThreadItem)
+	collect = func(items []ThreadItem) {
+		for _, item := range items {
+			pubkeys = append(pubkeys, item.Event.PubKey)
+			collect(item.Replies)
+		}
+	}
+	collect(items)
+
+	profiles := fetchProfiles(ctx, pubkeys)
+
+	var fill func(items []ThreadItem)
+	fill = func(items []ThreadItem) {
+		for i := range items {
+			items[i].Event.author = profiles[items[i].Event.PubKey]
+			fill(items[i].Replies)
+		}
+	}
+	fill(items)
+}
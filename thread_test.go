@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsThreadable(t *testing.T) {
+	for _, tc := range []struct {
+		kind     nostr.Kind
+		expected bool
+	}{
+		{1, true},
+		{11, true},
+		{1111, true},
+		{0, false},
+		{30023, false},
+		{1068, false},
+	} {
+		assert.Equal(t, tc.expected, isThreadable(tc.kind), "kind %d", tc.kind)
+	}
+}
+
+func TestNewThreadItem(t *testing.T) {
+	ee := NewEnhancedEventWithoutMetadata(nostr.Event{
+		Kind:    1,
+		PubKey:  nostr.MustPubKeyFromHex("7bdef7be22dd8e59f4600e044aa53a1cf975a9dc7d27df5833bc77db784a5805"),
+		Content: "hello <b>world</b>\nbye",
+	})
+
+	item := newThreadItem(ee, 2)
+	assert.Equal(t, 2, item.Depth)
+	assert.Equal(t, template.HTML("hello &lt;b&gt;world&lt;/b&gt;<br/>bye"), item.Content)
+	assert.Contains(t, item.Nevent, "nevent1")
+}
+
+func TestMoreRepliesLink(t *testing.T) {
+	assert.Equal(t, "?replies=20", moreRepliesLink(url.Values{}, 20))
+	assert.Equal(t, "?embed=yes&replies=40&widget=thread",
+		moreRepliesLink(url.Values{"embed": {"yes"}, "widget": {"thread"}, "replies": {"20"}}, 40))
+}
+
+// saveTestReply stores a kind 1 reply to parent in the thread started by root, using NIP-10 markers
+func saveTestReply(t *testing.T, root nostr.Event, parent nostr.Event, createdAt nostr.Timestamp, content string, extra ...nostr.Tag) nostr.Event {
+	t.Helper()
+	tags := nostr.Tags{{"e", root.ID.Hex(), "", "root"}}
+	if parent.ID != root.ID {
+		tags = append(tags, nostr.Tag{"e", parent.ID.Hex(), "", "reply"})
+	}
+	return saveTestEvent(t, nostr.Event{
+		Kind:      1,
+		PubKey:    parent.PubKey,
+		CreatedAt: createdAt,
+		Content:   content,
+		Tags:      append(tags, extra...),
+	})
+}
+
+func TestThreadReplies(t *testing.T) {
+	setupTestStores(t)
+
+	root := saveTestEvent(t, nostr.Event{
+		Kind:      1,
+		PubKey:    nostr.MustPubKeyFromHex("7bdef7be22dd8e59f4600e044aa53a1cf975a9dc7d27df5833bc77db784a5805"),
+		CreatedAt: 1000,
+		Content:   "root",
+	})
+	second := saveTestReply(t, root, root, 1002, "second")
+	first := saveTestReply(t, root, root, 1001, "first")
+	saveTestReply(t, root, root, 1003, "hidden", nostr.Tag{"t", "adult"})
+	saveTestReply(t, root, first, 1004, "under first")
+
+	// a chain below the second reply that goes deeper than we show
+	parent := second
+	for i := 2; i <= THREAD_MAX_DEPTH+2; i++ {
+		parent = saveTestReply(t, root, parent, nostr.Timestamp(1010+i), "depth "+strconv.Itoa(i))
+	}
+
+	levels := 0
+	replies, hasMore := threadReplies(NewEnhancedEventWithoutMetadata(root), 0, func(nostr.Filter) { levels++ })
+	assert.False(t, hasMore)
+	assert.Equal(t, THREAD_MAX_DEPTH, levels)
+
+	// hidden replies are skipped and the rest are sorted by time
+	assert.Len(t, replies, 2)
+	assert.Equal(t, "first", replies[0].Event.Content)
+	assert.Equal(t, "second", replies[1].Event.Content)
+
+	// nested replies are only attached under their immediate parent
+	assert.Len(t, replies[0].Replies, 1)
+	assert.Equal(t, "under first", replies[0].Replies[0].Event.Content)
+	assert.Equal(t, 2, replies[0].Replies[0].Depth)
+
+	deepest := replies[1]
+	for len(deepest.Replies) > 0 {
+		assert.Len(t, deepest.Replies, 1)
+		deepest = deepest.Replies[0]
+	}
+	assert.Equal(t, THREAD_MAX_DEPTH, deepest.Depth)
+	assert.Equal(t, "depth "+strconv.Itoa(THREAD_MAX_DEPTH), deepest.Event.Content)
+}
+
+func TestThreadRepliesPagination(t *testing.T) {
+	setupTestStores(t)
+
+	root := saveTestEvent(t, nostr.Event{
+		Kind:      1,
+		PubKey:    nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"),
+		CreatedAt: 1000,
+		Content:   "root",
+	})
+	for i := range THREAD_PAGE_SIZE + 5 {
+		saveTestReply(t, root, root, nostr.Timestamp(1001+i), "reply "+strconv.Itoa(i))
+	}
+
+	event := NewEnhancedEventWithoutMetadata(root)
+	noop := func(nostr.Filter) {}
+
+	replies, hasMore := threadReplies(event, 0, noop)
+	assert.True(t, hasMore)
+	assert.Len(t, replies, THREAD_PAGE_SIZE)
+	assert.Equal(t, "reply 0", replies[0].Event.Content)
+
+	replies, hasMore = threadReplies(event, THREAD_PAGE_SIZE, noop)
+	assert.False(t, hasMore)
+	assert.Len(t, replies, 5)
+	assert.Equal(t, "reply "+strconv.Itoa(THREAD_PAGE_SIZE), replies[0].Event.Content)
+
+	replies, hasMore = threadReplies(event, THREAD_PAGE_SIZE+5, noop)
+	assert.False(t, hasMore)
+	assert.Empty(t, replies)
+}
+
+func TestFetchThreadAncestors(t *testing.T) {
+	setupTestStores(t)
+
+	// a cancelled context keeps everything in the local store
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	root := saveTestEvent(t, nostr.Event{
+		Kind:      1,
+		PubKey:    nostr.MustPubKeyFromHex("97c70a44366a6535c145b333f973ea86dfdc2d7a99da618c40c64705ad98e322"),
+		CreatedAt: 1000,
+		Content:   "root",
+	})
+	a := saveTestReply(t, root, root, 1001, "a")
+	b := saveTestReply(t, root, a, 1002, "b")
+	c := saveTestReply(t, root, b, 1003, "c")
+
+	ancestors := fetchThreadAncestors(ctx, NewEnhancedEventWithoutMetadata(c))
+	contents := make([]string, len(ancestors))
+	for i, ancestor := range ancestors {
+		contents[i] = ancestor.Event.Content
+	}
+	assert.Equal(t, []string{"root", "a", "b"}, contents)
+
+	// we stop at hidden parents
+	hidden := saveTestReply(t, root, a, 1004, "hidden", nostr.Tag{"t", "adult"})
+	d := saveTestReply(t, root, hidden, 1005, "d")
+	assert.Empty(t, fetchThreadAncestors(ctx, NewEnhancedEventWithoutMetadata(d)))
+
+	// and after a while
+	parent := root
+	for i := range THREAD_MAX_ANCESTORS + 3 {
+		parent = saveTestReply(t, root, parent, nostr.Timestamp(1100+i), "long "+strconv.Itoa(i))
+	}
+	ancestors = fetchThreadAncestors(ctx, NewEnhancedEventWithoutMetadata(parent))
+	assert.Len(t, ancestors, THREAD_MAX_ANCESTORS)
+	assert.Equal(t, "long 2", ancestors[0].Event.Content)
+}
@@ -7,9 +7,13 @@ import (
 	"fmt"
 	"html"
 	"html/template"
+	"mime"
 	"net/http"
+	"net/url"
+	"path"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -43,6 +47,7 @@ var (
 	imageExtensionMatcher = regexp.MustCompile(`.*\.(png|jpg|jpeg|gif|webp|avif)((\?|\#).*)?$`)
 	videoExtensionMatcher = regexp.MustCompile(`.*\.(mp4|ogg|webm|mov)((\?|\#).*)?$`)
 	urlRegex              = xurls.Strict()
+	relativeLinkMatcher   = regexp.MustCompile(`\b(href|src)=(["'])/([^/])`)
 
 	markdownExtractor = me.NewExtractor()
 )
@@ -68,6 +73,14 @@ var kindNames = map[nostr.Kind]string{
 	43:    "Channel Hide Message",
 	44:    "Channel Mute User",
 	1063:  "File Metadata",
+	1068:  "Poll",
+	1617:  "Patch",
+	1621:  "Issue",
+	1630:  "Open Status",
+	1631:  "Applied / Merged Status",
+	1632:  "Closed Status",
+	1633:  "Draft Status",
+	1018:  "Poll Response",
 	1111:  "Comment",
 	1311:  "Live Chat Message",
 	1984:  "Reporting",
@@ -90,8 +103,13 @@ var kindNames = map[nostr.Kind]string{
 	30018: "Create or update a product",
 	30023: "Long-form Content",
 	30078: "Application-specific Data",
+	30402: "Classified Listing",
+	30617: "Repository Announcement",
 	30818: "Wiki article",
 	30311: "Live Event",
+	31922: "Date-Based Calendar Event",
+	31923: "Time-Based Calendar Event",
+	31924: "Calendar",
 	39000: "Group Metadata",
 	39089: "Starter Pack",
 }
@@ -117,6 +135,14 @@ var kindNIPs = map[nostr.Kind]string{
 	43:    "28",
 	44:    "28",
 	1063:  "94",
+	1068:  "88",
+	1617:  "34",
+	1621:  "34",
+	1630:  "34",
+	1631:  "34",
+	1632:  "34",
+	1633:  "34",
+	1018:  "88",
 	1111:  "22",
 	1311:  "53",
 	1984:  "56",
@@ -139,8 +165,13 @@ var kindNIPs = map[nostr.Kind]string{
 	30018: "15",
 	30023: "23",
 	30078: "78",
+	30402: "99",
+	30617: "34",
 	30818: "54",
 	30311: "53",
+	31922: "52",
+	31923: "52",
+	31924: "52",
 	39000: "29",
 	39089: "51",
 }
@@ -567,12 +598,88 @@ func isValidShortcode(s string) bool {
 	return true
 }
 
+// parseUntil reads the "until" cursor from the querystring, 0 means we want the latest events
+func parseUntil(r *http.Request) nostr.Timestamp {
+	until, _ := strconv.ParseInt(r.URL.Query().Get("until"), 10, 64)
+	if until < 0 {
+		return 0
+	}
+	return nostr.Timestamp(until)
+}
+
+// nextPageURL is the URL for the page of events older than the given ones, or "" if there are
+// not enough of them to fill a page (which means we've reached the end). the next page starts at
+// the timestamp of the oldest event, since others may share it, and the events we've already shown
+// with that timestamp are listed in "seen" so skipSeenEvents can leave them out
+func nextPageURL(r *http.Request, base string, events []EnhancedEvent, limit int) string {
+	// the events we skipped on this page also count, otherwise we'd think we reached the end
+	if len(events) == 0 || len(events)+len(parseSeen(r)) < limit {
+		return ""
+	}
+	oldest := events[len(events)-1].CreatedAt
+	for _, ee := range events {
+		if ee.CreatedAt < oldest {
+			oldest = ee.CreatedAt
+		}
+	}
+
+	seen := make([]string, 0, 1)
+	for _, ee := range events {
+		if ee.CreatedAt == oldest {
+			seen = append(seen, ee.ID.Hex()[0:SEEN_ID_LENGTH])
+		}
+	}
+
+	qs := r.URL.Query()
+	qs.Set("until", strconv.FormatInt(int64(oldest), 10))
+	qs.Set("seen", strings.Join(seen, ","))
+	return base + "?" + qs.Encode()
+}
+
+// ids in the "seen" querystring are shortened, this is enough to tell apart events from the same second
+const SEEN_ID_LENGTH = 16
+
+func parseSeen(r *http.Request) []string {
+	seen := r.URL.Query().Get("seen")
+	if seen == "" {
+		return nil
+	}
+	return strings.Split(seen, ",")
+}
+
+// skipSeenEvents removes the events that were already shown at the end of the previous page
+func skipSeenEvents(r *http.Request, events []EnhancedEvent) []EnhancedEvent {
+	until := parseUntil(r)
+	seen := parseSeen(r)
+	if until == 0 || len(seen) == 0 {
+		return events
+	}
+	return slices.DeleteFunc(events, func(ee EnhancedEvent) bool {
+		return ee.CreatedAt == until && slices.Contains(seen, ee.ID.Hex()[0:SEEN_ID_LENGTH])
+	})
+}
+
+// absoluteLinks makes the links to our own pages in some HTML absolute, for when it is shown elsewhere
+func absoluteLinks(content string) string {
+	return relativeLinkMatcher.ReplaceAllString(content, "${1}=${2}https://"+s.Domain+"/${3}")
+}
+
 func appendUnique[I comparable](arr []I, item ...I) []I {
 	for _, item := range item {
 		if slices.Contains(arr, item) {
-			return arr
+			continue
 		}
 		arr = append(arr, item)
 	}
 	return arr
 }
+
+// imageTypeFromURL guesses the type of an image from the extension in its url, it is empty when there is none
+func imageTypeFromURL(imageURL string) string {
+	if u, err := url.Parse(imageURL); err == nil {
+		if typ := mime.TypeByExtension(path.Ext(u.Path)); strings.HasPrefix(typ, "image/") {
+			return typ
+		}
+	}
+	return ""
+}
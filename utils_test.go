@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/assert"
+)
+
+func pageEvent(id string, createdAt nostr.Timestamp) EnhancedEvent {
+	eventId, _ := nostr.IDFromHex(id)
+	return NewEnhancedEventWithoutMetadata(nostr.Event{
+		ID:        eventId,
+		CreatedAt: createdAt,
+		Kind:      1,
+	})
+}
+
+func TestNextPageURL(t *testing.T) {
+	a := pageEvent("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", 300)
+	b := pageEvent("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", 200)
+	c := pageEvent("cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc", 200)
+
+	for _, tc := range []struct {
+		name     string
+		target   string
+		events   []EnhancedEvent
+		limit    int
+		expected string
+	}{
+		{"empty", "/npub1x", nil, 3, ""},
+		{"last page", "/npub1x", []EnhancedEvent{a, b}, 3, ""},
+		{
+			"events sharing the oldest timestamp",
+			"/npub1x",
+			[]EnhancedEvent{a, b, c},
+			3,
+			"/npub1x?seen=bbbbbbbbbbbbbbbb%2Ccccccccccccccccc&until=200",
+		},
+		{
+			"keeps the other parameters and counts skipped events",
+			"/npub1x?kinds=1&until=500&seen=dddddddddddddddd",
+			[]EnhancedEvent{a, b},
+			3,
+			// one event was skipped from this page so it still counts as full
+			"/npub1x?kinds=1&seen=bbbbbbbbbbbbbbbb&until=200",
+		},
+	} {
+		r := httptest.NewRequest("GET", tc.target, nil)
+		next := nextPageURL(r, r.URL.Path, tc.events, tc.limit)
+		assert.Equal(t, tc.expected, next, tc.name)
+	}
+}
+
+func TestSkipSeenEvents(t *testing.T) {
+	a := pageEvent("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", 200)
+	b := pageEvent("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", 200)
+	c := pageEvent("cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc", 100)
+
+	qs := url.Values{"until": {"200"}, "seen": {"aaaaaaaaaaaaaaaa"}}
+	r := httptest.NewRequest("GET", "/npub1x?"+qs.Encode(), nil)
+	assert.Equal(t, []EnhancedEvent{b, c}, skipSeenEvents(r, []EnhancedEvent{a, b, c}))
+
+	// without a cursor nothing is skipped
+	r = httptest.NewRequest("GET", "/npub1x?seen=aaaaaaaaaaaaaaaa", nil)
+	assert.Len(t, skipSeenEvents(r, []EnhancedEvent{a, b, c}), 3)
+}
+
+func TestImageTypeFromURL(t *testing.T) {
+	for _, tc := range []struct {
+		url      string
+		expected string
+	}{
+		{"https://njump.test/image/" + testNpub + ".png?style=twitter", "image/png"},
+		{"https://example.com/cover.JPG", "image/jpeg"},
+		{"https://example.com/cover.webp#x", "image/webp"},
+		{"https://njump.test/image/" + testNpub, ""},
+		{"https://example.com/video.mp4", ""},
+		{"", ""},
+	} {
+		assert.Equal(t, tc.expected, imageTypeFromURL(tc.url), tc.url)
+	}
+}
+
+func TestAppendUnique(t *testing.T) {
+	assert.Equal(t, []string{"a", "b", "c", "d"}, appendUnique([]string{"a", "b"}, "b", "c", "a", "d", "c"))
+	assert.Equal(t, []string{"a"}, appendUnique(nil, "a", "a"))
+}
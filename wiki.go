@@ -0,0 +1,143 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"fiatjaf.com/nostr"
+	"golang.org/x/sync/singleflight"
+)
+
+// wikiWoT is a cached web-of-trust used to rank wiki articles: the trusted pubkeys and the people they follow
+var wikiWoT struct {
+	sync.RWMutex
+	scores    map[nostr.PubKey]int
+	expiresAt time.Time
+	refresh   singleflight.Group
+}
+
+// wotScores gives each pubkey a score based on how many of the trusted pubkeys follow it,
+// with the trusted pubkeys themselves scoring above everybody else. the follow lists are fetched
+// outside of any request, only the first call waits for them, after that expired scores are
+// still returned while they're refreshed in the background.
+func wotScores() map[nostr.PubKey]int {
+	wikiWoT.RLock()
+	scores, expiresAt := wikiWoT.scores, wikiWoT.expiresAt
+	wikiWoT.RUnlock()
+
+	if scores == nil {
+		v, _, _ := wikiWoT.refresh.Do("wot", refreshWoTScores)
+		return v.(map[nostr.PubKey]int)
+	}
+	if time.Now().After(expiresAt) {
+		go wikiWoT.refresh.Do("wot", refreshWoTScores)
+	}
+	return scores
+}
+
+func refreshWoTScores() (any, error) {
+	scores := computeWoTScores(s.trustedPubKeys, func(pubkey nostr.PubKey) []nostr.PubKey {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		defer cancel()
+
+		follows := sys.FetchFollowList(ctx, pubkey)
+		pubkeys := make([]nostr.PubKey, len(follows.Items))
+		for i, follow := range follows.Items {
+			pubkeys[i] = follow.Pubkey
+		}
+		return pubkeys
+	})
+
+	wikiWoT.Lock()
+	wikiWoT.scores = scores
+	wikiWoT.expiresAt = time.Now().Add(time.Hour * 6)
+	wikiWoT.Unlock()
+
+	return scores, nil
+}
+
+func computeWoTScores(trusted []nostr.PubKey, followsOf func(nostr.PubKey) []nostr.PubKey) map[nostr.PubKey]int {
+	scores := make(map[nostr.PubKey]int, 1000)
+	for _, pubkey := range trusted {
+		scores[pubkey] += len(trusted) + 1
+		for _, follow := range followsOf(pubkey) {
+			scores[follow]++
+		}
+	}
+	return scores
+}
+
+// wikiTopicExists checks if we know of any article for the given topic in our local store
+func wikiTopicExists(topic string) bool {
+	for range sys.Store.QueryEvents(nostr.Filter{
+		Kinds: []nostr.Kind{30818},
+		Tags:  nostr.TagMap{"d": []string{topic}},
+		Limit: 1,
+	}, 1) {
+		return true
+	}
+	return false
+}
+
+type WikiArticle struct {
+	EnhancedEvent
+	Trust   int
+	Trusted bool
+}
+
+// wikiTopicArticles fetches all the kind 30818 articles with the given d-tag and ranks them
+// by the web-of-trust score of their authors, then by recency
+func wikiTopicArticles(ctx context.Context, topic string) []WikiArticle {
+	filter := nostr.Filter{
+		Kinds: []nostr.Kind{30818},
+		Tags:  nostr.TagMap{"d": []string{topic}},
+	}
+
+	relays := make([]string, 0, 3)
+	for len(relays) < 3 {
+		relays = appendUnique(relays, sys.FallbackRelays.Next())
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, time.Second*4)
+	for ie := range sys.Pool.FetchMany(fetchCtx, relays, filter, nostr.SubscriptionOptions{Label: "wikitopic"}) {
+		sys.Store.SaveEvent(ie.Event)
+	}
+	cancel()
+
+	scores := wotScores()
+
+	articles := make([]WikiArticle, 0, 10)
+	for evt := range sys.Store.QueryEvents(filter, DB_MAX_LIMIT) {
+		if banned, _ := isEventBanned(evt.ID); banned {
+			continue
+		}
+		if banned, _ := isPubkeyBanned(evt.PubKey); banned {
+			continue
+		}
+		if hasProhibitedWordOrTag(&evt) {
+			continue
+		}
+
+		score := scores[evt.PubKey]
+		articles = append(articles, WikiArticle{
+			EnhancedEvent: NewEnhancedEvent(ctx, evt),
+			Trust:         score,
+			Trusted:       slices.Contains(s.trustedPubKeys, evt.PubKey),
+		})
+	}
+
+	slices.SortFunc(articles, compareWikiArticles)
+
+	return articles
+}
+
+// compareWikiArticles puts the most trusted articles first, then the most recent
+func compareWikiArticles(a, b WikiArticle) int {
+	if a.Trust != b.Trust {
+		return cmp.Compare(b.Trust, a.Trust)
+	}
+	return cmp.Compare(b.CreatedAt, a.CreatedAt)
+}
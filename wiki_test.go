@@ -0,0 +1,45 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"fiatjaf.com/nostr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeWoTScores(t *testing.T) {
+	alice := nostr.MustPubKeyFromHex("7bdef7be22dd8e59f4600e044aa53a1cf975a9dc7d27df5833bc77db784a5805")
+	bob := nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+	carol := nostr.MustPubKeyFromHex("97c70a44366a6535c145b333f973ea86dfdc2d7a99da618c40c64705ad98e322")
+
+	follows := map[nostr.PubKey][]nostr.PubKey{
+		alice: {bob, carol},
+		bob:   {carol},
+	}
+	scores := computeWoTScores([]nostr.PubKey{alice, bob}, func(pubkey nostr.PubKey) []nostr.PubKey {
+		return follows[pubkey]
+	})
+
+	assert.Equal(t, 3, scores[alice])
+	assert.Equal(t, 4, scores[bob], "trusted and followed by alice")
+	assert.Equal(t, 2, scores[carol])
+}
+
+func TestCompareWikiArticles(t *testing.T) {
+	article := func(trust int, createdAt nostr.Timestamp) WikiArticle {
+		return WikiArticle{
+			EnhancedEvent: NewEnhancedEventWithoutMetadata(nostr.Event{Kind: 30818, CreatedAt: createdAt}),
+			Trust:         trust,
+		}
+	}
+
+	articles := []WikiArticle{article(0, 300), article(2, 100), article(0, 400), article(2, 200)}
+	slices.SortFunc(articles, compareWikiArticles)
+
+	order := make([]nostr.Timestamp, len(articles))
+	for i, a := range articles {
+		order[i] = a.CreatedAt
+	}
+	assert.Equal(t, []nostr.Timestamp{200, 100, 400, 300}, order)
+}
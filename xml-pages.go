@@ -20,12 +20,16 @@ type SitemapPage struct {
 
 	// for the profile sitemap
 	Metadata sdk.ProfileMetadata
+	TabPath  string
 
 	// for the relay sitemap
 	RelayHostname string
 	Info          nip11.RelayInformationDocument
 
-	// for the profile and relay sitemaps
+	// for the hashtag sitemap
+	Hashtag string
+
+	// for the profile, relay and hashtag sitemaps
 	LastNotes []EnhancedEvent
 
 	// for the archive sitemap
@@ -42,8 +46,13 @@ var (
 )
 
 type SitemapIndexPage struct {
-	Host  string
-	Npubs []string
+	Host     string
+	Sitemaps []SitemapIndexEntry
+}
+
+type SitemapIndexEntry struct {
+	Path       string
+	ModifiedAt string
 }
 
 func (*SitemapIndexPage) TemplateText() string { return tmplSitemapIndex }
@@ -61,13 +70,18 @@ type RSSPage struct {
 
 	// for the profile RSS
 	Metadata sdk.ProfileMetadata
+	TabPath  string
 
 	// for the relay RSS
 	RelayHostname string
 	Info          nip11.RelayInformationDocument
 
-	// for the profile and relay RSSs
+	// for the hashtag RSS
+	Hashtag string
+
+	// for the profile, relay and hashtag RSSs
 	LastNotes []EnhancedEvent
+	NextPage  string
 
 	// for the archive RSS
 	PathPrefix string
@@ -75,3 +89,15 @@ type RSSPage struct {
 }
 
 func (*RSSPage) TemplateText() string { return tmplRSS }
+
+var (
+	//go:embed xml/opensearch.xml
+	tmplOpenSearch     string
+	OpenSearchTemplate = tmpl.MustCompile(&OpenSearchPage{})
+)
+
+type OpenSearchPage struct {
+	Host string
+}
+
+func (*OpenSearchPage) TemplateText() string { return tmplOpenSearch }